@@ -0,0 +1,382 @@
+package domainverifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/egbakou/domainverifier/config"
+	"github.com/miekg/dns"
+	"github.com/segmentio/ksuid"
+	"net/http"
+	"strings"
+)
+
+// Method identifies a verification method.
+type Method string
+
+const (
+	MethodHtmlMeta    Method = "html_meta"
+	MethodJson        Method = "json"
+	MethodXml         Method = "xml"
+	MethodTextFile    Method = "text_file"
+	MethodHttpHeader  Method = "http_header"
+	MethodTxtRecord   Method = "txt_record"
+	MethodCnameRecord Method = "cname_record"
+)
+
+// allMethods lists the verification methods in the order they are checked by InstructionBundle.Verify.
+var allMethods = []Method{
+	MethodHtmlMeta, MethodJson, MethodXml, MethodTextFile, MethodHttpHeader, MethodTxtRecord, MethodCnameRecord,
+}
+
+// UnknownMethodError indicates that the verification method is not supported or not configured.
+var UnknownMethodError = errors.New("unknown or unconfigured verification method")
+
+// BundleOptions customizes the codes and the CNAME entry of an InstructionBundle.
+type BundleOptions struct {
+	CodePerMethod bool   // issue one code per method instead of a single code shared by all methods
+	CnameTarget   string // target of the CNAME record (e.g. verify.myapp.com), no CNAME entry when empty
+	// Store, when not nil, records the issued bundle as a pending Challenge of Domain,
+	// verified by any of its methods (see InstructionBundle.Challenge).
+	Store   ChallengeStore
+	Domain  string // domain the bundle is issued for, required with Store
+	Account string // optional account the domain is verified for, recorded with the challenge
+}
+
+// InstructionBundle holds the instructions of every verification method issued at once for a domain,
+// so the user can pick any of them.
+// Methods holds the generator configs, including the issued codes, needed to verify the domain later:
+// store it along with the domain (it can be serialized as JSON).
+type InstructionBundle struct {
+	Methods     *config.Methods
+	HtmlMeta    *HtmlMetaInstruction
+	Json        *FileInstruction
+	Xml         *FileInstruction
+	TextFile    *FileInstruction
+	HttpHeader  *HttpHeaderInstruction
+	TxtRecord   *DnsRecordInstruction
+	CnameRecord *DnsRecordInstruction
+	Challenge   *Challenge // challenge recorded in BundleOptions.Store, nil without a store
+}
+
+// GenerateAll generates the instructions of every verification method at once.
+// appName is the name of the app that is requesting the verification (e.g. google, bing, etc.).
+// The JSON and XML files, the header and the TXT record are named like GenerateJson, GenerateXml,
+// GenerateHttpHeader and GenerateTxtRecord name them.
+// Unlike GenerateHtmlMeta, the meta tag is named appName-site-verification (e.g. myapp-site-verification).
+// Unlike GenerateTextFile, which puts a code in the file name, the text file is named
+// appName-site-verification.html (e.g. myapp-site-verification.html) and holds the code,
+// so that it can be shared with the other methods.
+// The CNAME entry is only issued when options.CnameTarget is set; its record name is derived from the code.
+// Note that the appName will be sanitized to non-alphanumeric characters.
+func GenerateAll(appName string, options *BundleOptions) (*InstructionBundle, error) {
+	if strings.TrimSpace(appName) == "" {
+		return nil, InvalidAppNameError
+	}
+
+	// the TXT record attribute keeps the case of the app name, as with GenerateTxtRecord
+	recordAppName := removeNonAlphanumeric(appName)
+	appName = sanitizeString(appName)
+	if appName == "" {
+		return nil, InvalidAppNameError
+	}
+
+	methods := &config.Methods{
+		HtmlMeta: &config.HmlMetaTagGenerator{
+			TagName: fmt.Sprintf("%s%s", appName, txtRecordAttributeSuffix),
+		},
+		Json: &config.JsonGenerator{
+			FileName:  fmt.Sprintf("%s%s", appName, jsonFileNameSuffix),
+			Attribute: fmt.Sprintf("%s%s", appName, jsonKeySuffix),
+		},
+		Xml: &config.XmlGenerator{
+			FileName: fmt.Sprintf("%s%s%s", strings.ToUpper(appName[:1]), appName[1:], xmlFileNameSuffix),
+			RootName: xmlRootName,
+		},
+		TextFile: &config.TextFileGenerator{
+			FileName: fmt.Sprintf("%s%s%s", appName, txtRecordAttributeSuffix, textFileExtension),
+		},
+		HttpHeader: &config.HttpHeaderGenerator{
+			HeaderName: fmt.Sprintf("%s%s%s", httpHeaderPrefix, appName, txtRecordAttributeSuffix),
+		},
+		TxtRecord: &config.TxtRecordGenerator{
+			HostName:        rootDomain,
+			RecordAttribute: fmt.Sprintf("%s%s", recordAppName, txtRecordAttributeSuffix),
+		},
+	}
+	return GenerateAllFromConfig(methods, options)
+}
+
+// GenerateAllFromConfig generates the instructions of every method configured in methods.
+// New codes are always issued: a single code shared by all methods, or one per method if options.CodePerMethod is true.
+// The configs are copied, methods is left untouched.
+// When methods.CnameRecord is nil and options.CnameTarget is set, a CNAME entry is added.
+// An empty CNAME record name is derived from the code, so that it is unique.
+// When options.Store is set, the bundle is recorded as a pending challenge of options.Domain.
+//
+// Example:
+//
+//	bundle, err := domainverifier.GenerateAllFromConfig(methods, &domainverifier.BundleOptions{
+//		Store:  store,
+//		Domain: "website.com",
+//	})
+//	// later
+//	challenge, err := store.Get(bundle.Challenge.ID)
+func GenerateAllFromConfig(methods *config.Methods, options *BundleOptions) (*InstructionBundle, error) {
+	if methods == nil {
+		return nil, config.InvalidConfigError
+	}
+	if options == nil {
+		options = &BundleOptions{}
+	}
+	if options.Store != nil && !IsValidDomainName(options.Domain) {
+		return nil, InvalidDomainError
+	}
+
+	sharedCode := ksuid.New().String()
+	nextCode := func() string {
+		if options.CodePerMethod {
+			return ksuid.New().String()
+		}
+		return sharedCode
+	}
+
+	bundle := &InstructionBundle{Methods: &config.Methods{}}
+	var err error
+
+	if methods.HtmlMeta != nil {
+		c := *methods.HtmlMeta
+		c.Code = nextCode()
+		bundle.Methods.HtmlMeta = &c
+		if bundle.HtmlMeta, err = GenerateHtmlMetaFromConfig(&c, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if methods.Json != nil {
+		c := *methods.Json
+		c.Code = nextCode()
+		c.Codes = append([]string(nil), c.Codes...)
+		bundle.Methods.Json = &c
+		if bundle.Json, err = GenerateJsonFromConfig(&c, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if methods.Xml != nil {
+		c := *methods.Xml
+		c.Code = nextCode()
+		c.Codes = append([]string(nil), c.Codes...)
+		bundle.Methods.Xml = &c
+		if bundle.Xml, err = GenerateXmlFromConfig(&c, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if methods.TextFile != nil {
+		c := *methods.TextFile
+		c.Content = nextCode()
+		bundle.Methods.TextFile = &c
+		if bundle.TextFile, err = GenerateTextFileFromConfig(&c, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if methods.HttpHeader != nil {
+		c := *methods.HttpHeader
+		c.Code = nextCode()
+		bundle.Methods.HttpHeader = &c
+		if bundle.HttpHeader, err = GenerateHttpHeaderFromConfig(&c, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if methods.TxtRecord != nil {
+		c := *methods.TxtRecord
+		c.RecordAttributeValue = nextCode()
+		bundle.Methods.TxtRecord = &c
+		if bundle.TxtRecord, err = GenerateTxtRecordFromConfig(&c, false); err != nil {
+			return nil, err
+		}
+	}
+
+	cnameConfig := methods.CnameRecord
+	if cnameConfig == nil && strings.TrimSpace(options.CnameTarget) != "" {
+		cnameConfig = &config.CnameRecordGenerator{RecordTarget: options.CnameTarget}
+	}
+	if cnameConfig != nil {
+		c := *cnameConfig
+		if strings.TrimSpace(c.RecordName) == "" {
+			// DNS names are case-insensitive, the code is lowercased to keep the record name as issued
+			c.RecordName = strings.ToLower(nextCode())
+		}
+		bundle.Methods.CnameRecord = &c
+		if bundle.CnameRecord, err = GenerateCnameRecordFromConfig(&c); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Store != nil {
+		if bundle.Challenge, err = recordChallenge(options.Store, options.Domain, options.Account, "", bundle.Methods); err != nil {
+			return nil, err
+		}
+	}
+	return bundle, nil
+}
+
+// Verify checks every method of the bundle and returns the first one that verifies the ownership of the domain.
+// dnsResolver is the DNS server used by the TXT and CNAME methods (dnsresolver.CloudflareDNS when empty).
+// When no method verifies it, the errors of the methods that could not be checked are returned as MethodErrors.
+//
+// Example:
+//
+//	method, verified, err := bundle.Verify("website.com", dnsresolver.GooglePublicDNS)
+//	if verified {
+//		fmt.Println("Verified with", method)
+//	}
+func (b *InstructionBundle) Verify(domain, dnsResolver string) (Method, bool, error) {
+	if b == nil || b.Methods == nil {
+		return "", false, config.InvalidConfigError
+	}
+	return verifyMethods(context.Background(), b.Methods, domain, checkOptions{dnsResolver: dnsResolver})
+}
+
+// MethodError is the error of a method that could not be checked.
+type MethodError struct {
+	Method Method
+	Err    error
+}
+
+func (e *MethodError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Method, e.Err)
+}
+
+func (e *MethodError) Unwrap() error {
+	return e.Err
+}
+
+// MethodErrors holds the errors of the methods that could not be checked, in the order they were checked.
+// errors.Is and errors.As match any of them.
+type MethodErrors []*MethodError
+
+func (e MethodErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Is reports whether the error of one of the methods matches target.
+func (e MethodErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of the methods that matches target.
+func (e MethodErrors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyMethods is InstructionBundle.Verify with the context and the options of checkMethod.
+func verifyMethods(ctx context.Context, methods *config.Methods, domain string, options checkOptions) (Method, bool, error) {
+	var errs MethodErrors
+	for _, method := range allMethods {
+		verified, err := checkMethod(ctx, method, methods, domain, options)
+		if err == UnknownMethodError {
+			continue
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", false, ctxErr
+		}
+		if err != nil {
+			errs = append(errs, &MethodError{Method: method, Err: err})
+			continue
+		}
+		if verified {
+			return method, true, nil
+		}
+	}
+
+	if len(errs) > 0 {
+		return "", false, errs
+	}
+	return "", false, nil
+}
+
+// CheckMethod verifies the ownership of the domain with one method, using its config in methods
+// (as issued in InstructionBundle.Methods). dnsResolver is only used by the DNS methods.
+// UnknownMethodError is returned when the method is not configured.
+func CheckMethod(method Method, methods *config.Methods, domain, dnsResolver string) (bool, error) {
+	return checkMethod(context.Background(), method, methods, domain, checkOptions{dnsResolver: dnsResolver})
+}
+
+// CheckMethodContext is CheckMethod with a context canceling the DNS queries and the HTTP requests of the check,
+// and the client of the HTTP requests. A client with DefaultHttpTimeout is used when client is nil.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	verified, err := domainverifier.CheckMethodContext(ctx, domainverifier.MethodJson, methods, "website.com", "", nil)
+func CheckMethodContext(ctx context.Context, method Method, methods *config.Methods, domain, dnsResolver string,
+	client *http.Client) (bool, error) {
+	return checkMethod(ctx, method, methods, domain, checkOptions{dnsResolver: dnsResolver, httpClient: client})
+}
+
+// checkOptions holds the settings of the requests of checkMethod.
+type checkOptions struct {
+	dnsResolver string           // DNS server of the TXT and CNAME checks, dnsresolver.CloudflareDNS when empty
+	httpClient  *http.Client     // client of the HTTP checks, defaultHttpClient when nil
+	observe     func(r *dns.Msg) // optional, called with each DNS answer, see checkDNSRecordContext
+}
+
+// checkMethod is CheckMethod with a context canceling the DNS queries and the HTTP requests.
+func checkMethod(ctx context.Context, method Method, methods *config.Methods, domain string, options checkOptions) (bool, error) {
+	if methods == nil {
+		return false, UnknownMethodError
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	client := options.httpClient
+	switch {
+	case method == MethodHtmlMeta && methods.HtmlMeta != nil:
+		c := methods.HtmlMeta
+		return checkHtmlMetaTag(ctx, client, domain, c.TagName, c.Code, &HtmlMetaOptions{Path: c.Path})
+	case method == MethodJson && methods.Json != nil:
+		c := methods.Json
+		return checkXmlOrJsonFile(ctx, client, false, domain, ResolveFilePath(c.Path, ensureFileExtension(c.FileName, ".json")),
+			c.VerificationCodes()[0], &FileMatchOptions{Mode: FileMatchKeyPath, KeyPath: "/" + EscapeKeyPathSegment(c.Attribute)})
+	case method == MethodXml && methods.Xml != nil:
+		c := methods.Xml
+		return checkXmlOrJsonFile(ctx, client, true, domain, ResolveFilePath(c.Path, ensureFileExtension(c.FileName, ".xml")),
+			c.VerificationCodes()[0], &FileMatchOptions{Mode: FileMatchKeyPath,
+				KeyPath: fmt.Sprintf("/%s/code", EscapeKeyPathSegment(xmlLocalName(c.RootName)))})
+	case method == MethodTextFile && methods.TextFile != nil:
+		c := methods.TextFile
+		return checkTextFile(ctx, client, domain, ResolveFilePath(c.Path, c.FileName), c.Content, TextMatchExact)
+	case method == MethodHttpHeader && methods.HttpHeader != nil:
+		c := methods.HttpHeader
+		return checkHttpHeader(ctx, client, domain, c.HeaderName, c.Code)
+	case method == MethodTxtRecord && methods.TxtRecord != nil:
+		c := methods.TxtRecord
+		return checkDNSRecordContext(ctx, options.dnsResolver, domain, c.HostName,
+			fmt.Sprintf("%s=%s", c.RecordAttribute, c.RecordAttributeValue), dns.TypeTXT, options.observe)
+	case method == MethodCnameRecord && methods.CnameRecord != nil:
+		c := methods.CnameRecord
+		return checkDNSRecordContext(ctx, options.dnsResolver, domain, c.RecordName, c.RecordTarget, dns.TypeCNAME, options.observe)
+	}
+
+	return false, UnknownMethodError
+}
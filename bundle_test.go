@@ -0,0 +1,247 @@
+package domainverifier
+
+import (
+	"context"
+	"errors"
+	"github.com/egbakou/domainverifier/config"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAll(t *testing.T) {
+	type args struct {
+		appName string
+		options *BundleOptions
+	}
+	testCases := []struct {
+		name          string
+		args          args
+		wantSameCodes bool
+		wantCname     bool
+		wantError     error
+	}{
+		{
+			name:          "Shared code without CNAME",
+			args:          args{appName: "MyApp", options: nil},
+			wantSameCodes: true,
+		},
+		{
+			name:          "Code per method with CNAME",
+			args:          args{appName: "MyApp", options: &BundleOptions{CodePerMethod: true, CnameTarget: "verify.myapp.com"}},
+			wantSameCodes: false,
+			wantCname:     true,
+		},
+		{
+			name:      "Empty app name",
+			args:      args{appName: " ", options: nil},
+			wantError: InvalidAppNameError,
+		},
+		{
+			name:      "App name without alphanumeric characters",
+			args:      args{appName: "!!", options: nil},
+			wantError: InvalidAppNameError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := GenerateAll(tc.args.appName, tc.args.options)
+			if err != tc.wantError {
+				t.Fatalf("expected error: %v, got: %v", tc.wantError, err)
+			}
+			if err != nil {
+				return
+			}
+
+			m := got.Methods
+			codes := []string{m.HtmlMeta.Code, m.Json.Code, m.Xml.Code, m.TextFile.Content, m.HttpHeader.Code,
+				m.TxtRecord.RecordAttributeValue}
+			sameCodes := true
+			for _, code := range codes[1:] {
+				if code != codes[0] {
+					sameCodes = false
+				}
+			}
+			if sameCodes != tc.wantSameCodes {
+				t.Errorf("expected shared codes: %v, got: %v", tc.wantSameCodes, codes)
+			}
+
+			if got.HtmlMeta == nil || got.Json == nil || got.Xml == nil || got.TextFile == nil ||
+				got.HttpHeader == nil || got.TxtRecord == nil {
+				t.Fatalf("expected an instruction for every method, got: %+v", got)
+			}
+			if !strings.Contains(got.HtmlMeta.Code, codes[0]) {
+				t.Errorf("expected the meta tag to contain %s, got: %s", codes[0], got.HtmlMeta.Code)
+			}
+			if got.Xml.FileName != "MyappSiteAuth.xml" {
+				t.Errorf("expected: %v, got: %v", "MyappSiteAuth.xml", got.Xml.FileName)
+			}
+			if got.TxtRecord.Record != "MyApp-site-verification="+m.TxtRecord.RecordAttributeValue {
+				t.Errorf("expected the TXT record to hold the code, got: %v", got.TxtRecord.Record)
+			}
+
+			// the names documented by GenerateAll
+			json, _ := GenerateJson(tc.args.appName)
+			header, _ := GenerateHttpHeader(tc.args.appName)
+			if got.Json.FileName != json.FileName || got.HttpHeader.HeaderName != header.HeaderName {
+				t.Errorf("expected the names of GenerateJson and GenerateHttpHeader, got: %v and %v",
+					got.Json.FileName, got.HttpHeader.HeaderName)
+			}
+			if m.HtmlMeta.TagName != "myapp-site-verification" || got.TextFile.FileName != "myapp-site-verification.html" {
+				t.Errorf("expected the meta tag and the text file named after myapp-site-verification, got: %v and %v",
+					m.HtmlMeta.TagName, got.TextFile.FileName)
+			}
+
+			if (got.CnameRecord != nil) != tc.wantCname {
+				t.Fatalf("expected CNAME: %v, got: %+v", tc.wantCname, got.CnameRecord)
+			}
+			if tc.wantCname {
+				if m.CnameRecord.RecordName == "" || m.CnameRecord.RecordName != strings.ToLower(m.CnameRecord.RecordName) {
+					t.Errorf("expected a lowercase derived record name, got: %v", m.CnameRecord.RecordName)
+				}
+				if got.CnameRecord.Record != tc.args.options.CnameTarget {
+					t.Errorf("expected: %v, got: %v", tc.args.options.CnameTarget, got.CnameRecord.Record)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateAllFromConfig(t *testing.T) {
+	methods := &config.Methods{
+		Json: &config.JsonGenerator{
+			FileName:  "myapp.json",
+			Attribute: "myapp_site_verification",
+			Code:      "old-code",
+		},
+		CnameRecord: &config.CnameRecordGenerator{
+			RecordName:   "verify",
+			RecordTarget: "verify.myapp.com",
+		},
+	}
+
+	got, err := GenerateAllFromConfig(methods, &BundleOptions{CnameTarget: "other.myapp.com"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if methods.Json.Code != "old-code" {
+		t.Errorf("expected the config to be left untouched, got code: %v", methods.Json.Code)
+	}
+	if got.Methods.Json.Code == "old-code" {
+		t.Errorf("expected a new code to be issued")
+	}
+	if got.HtmlMeta != nil || got.Xml != nil || got.TxtRecord != nil {
+		t.Errorf("expected only the configured methods, got: %+v", got)
+	}
+	if got.CnameRecord.HostName != "verify" || got.CnameRecord.Record != "verify.myapp.com" {
+		t.Errorf("expected the configured CNAME record, got: %+v", got.CnameRecord)
+	}
+
+	methods.Json.FileName = "../myapp.json"
+	if _, err := GenerateAllFromConfig(methods, nil); err == nil {
+		t.Errorf("expected a validation error")
+	}
+	if _, err := GenerateAllFromConfig(nil, nil); err != config.InvalidConfigError {
+		t.Errorf("expected: %v, got: %v", config.InvalidConfigError, err)
+	}
+}
+
+func TestGenerateAllFromConfigStore(t *testing.T) {
+	store := NewMemoryChallengeStore()
+	methods := &config.Methods{TxtRecord: &config.TxtRecordGenerator{HostName: "@", RecordAttribute: "myapp-site-verification"}}
+
+	got, err := GenerateAllFromConfig(methods, &BundleOptions{Store: store, Domain: "website.com", Account: "alice"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got.Challenge == nil || got.Challenge.ID == "" {
+		t.Fatalf("expected a recorded challenge, got: %+v", got.Challenge)
+	}
+	stored, err := store.Get(got.Challenge.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if stored.Domain != "website.com" || stored.Account != "alice" || stored.Status != ChallengePending || stored.Method != "" ||
+		stored.Methods.TxtRecord.RecordAttributeValue != got.Methods.TxtRecord.RecordAttributeValue {
+		t.Errorf("expected the issued challenge, got: %+v", stored)
+	}
+
+	if _, err := GenerateAllFromConfig(methods, &BundleOptions{Store: store}); err != InvalidDomainError {
+		t.Errorf("expected: %v, got: %v", InvalidDomainError, err)
+	}
+	if got, err := GenerateAllFromConfig(methods, &BundleOptions{Domain: "website.com"}); err != nil || got.Challenge != nil {
+		t.Errorf("expected no challenge without a store, got: %+v (%v)", got.Challenge, err)
+	}
+	if found, _ := store.FindByDomain("website.com", ""); len(found) != 1 {
+		t.Errorf("expected: %v, got: %v", 1, len(found))
+	}
+}
+
+func TestCheckMethodNotConfigured(t *testing.T) {
+	methods := &config.Methods{TxtRecord: &config.TxtRecordGenerator{HostName: "@", RecordAttribute: "myapp"}}
+	for _, method := range []Method{MethodHtmlMeta, MethodCnameRecord, Method("unknown")} {
+		if _, err := CheckMethod(method, methods, "example.com", ""); err != UnknownMethodError {
+			t.Errorf("method %s: expected: %v, got: %v", method, UnknownMethodError, err)
+		}
+	}
+
+	bundle := &InstructionBundle{Methods: &config.Methods{}}
+	if method, verified, err := bundle.Verify("example.com", ""); method != "" || verified || err != nil {
+		t.Errorf("expected no method to be checked, got: %v, %v, %v", method, verified, err)
+	}
+}
+
+func TestCheckMethodKeyPaths(t *testing.T) {
+	client := startTestWebServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ns.xml":
+			_, _ = w.Write([]byte(`<ns:verification xmlns:ns="urn:myapp"><code>1234</code></ns:verification>`))
+		case "/myapp.json":
+			_, _ = w.Write([]byte(`{"myapp/site.verification": "1234"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	methods := &config.Methods{
+		Xml:  &config.XmlGenerator{FileName: "ns.xml", RootName: "ns:verification", Code: "1234"},
+		Json: &config.JsonGenerator{FileName: "myapp.json", Attribute: "myapp/site.verification", Code: "1234"},
+	}
+	for _, method := range []Method{MethodXml, MethodJson} {
+		if verified, err := CheckMethodContext(context.Background(), method, methods, "website.com", "", client); !verified || err != nil {
+			t.Errorf("method %s: expected: %v, got: %v, %v", method, true, verified, err)
+		}
+	}
+}
+
+func TestVerifyMethodErrors(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := conn.LocalAddr().String()
+	conn.Close() // the queries are refused
+
+	bundle := &InstructionBundle{Methods: &config.Methods{
+		TxtRecord:   &config.TxtRecordGenerator{HostName: "@", RecordAttribute: "myapp", RecordAttributeValue: "1234"},
+		CnameRecord: &config.CnameRecordGenerator{RecordName: "abcd", RecordTarget: "verify.myapp.com"},
+	}}
+	method, verified, err := bundle.Verify("website.com", resolver)
+	if method != "" || verified {
+		t.Errorf("expected no method, got: %v, %v", method, verified)
+	}
+
+	var errs MethodErrors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Method != MethodTxtRecord || errs[1].Method != MethodCnameRecord {
+		t.Fatalf("expected the errors of the TXT and CNAME methods, got: %v", err)
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		t.Errorf("expected a *net.OpError among %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), string(MethodTxtRecord)+": ") {
+		t.Errorf("expected the errors to name their method, got: %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package domainverifier
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLanguage is the language of the instructions when no requested language has a translation.
+const DefaultLanguage = "en"
+
+// MessageKey identifies a sentence of the instructions in a Catalog.
+type MessageKey string
+
+const (
+	MessageMetaTagHomePage   MessageKey = "meta_tag.home_page"
+	MessageMetaTagPage       MessageKey = "meta_tag.page"
+	MessageMetaTagHead       MessageKey = "meta_tag.head"
+	MessageMetaTagKeep       MessageKey = "meta_tag.keep"
+	MessageJsonFileCreate    MessageKey = "json_file.create"
+	MessageXmlFileCreate     MessageKey = "xml_file.create"
+	MessageTextFileCreate    MessageKey = "text_file.create"
+	MessageFileUploadRoot    MessageKey = "file.upload_root"
+	MessageFileUploadPath    MessageKey = "file.upload_path"
+	MessageHttpHeaderAdd     MessageKey = "http_header.add"
+	MessageHttpHeaderKeep    MessageKey = "http_header.keep"
+	MessageTxtRecordCreate   MessageKey = "txt_record.create"
+	MessageCnameRecordCreate MessageKey = "cname_record.create"
+	MessageDnsHostCreate     MessageKey = "dns_host.create"
+	MessageDnsHostEmptyName  MessageKey = "dns_host.empty_name"
+)
+
+// InvalidLanguageError indicates that the language tag of a catalog is empty.
+var InvalidLanguageError = errors.New("language tag cannot be empty")
+
+// Catalog holds the translations of the sentences of the instructions for one language.
+// In a sentence, %s is replaced by the next value of the step and %[n]s by its n-th value,
+// so that a translation can change the order of the values.
+type Catalog map[MessageKey]string
+
+var (
+	catalogsMutex sync.RWMutex
+	catalogs      = map[string]Catalog{
+		"en": {
+			MessageMetaTagHomePage:   "Copy and paste the %s tag into your site's home page.",
+			MessageMetaTagPage:       "Copy and paste the %s tag into the page %s of your site.",
+			MessageMetaTagHead:       "It should go in the %s section, before the first %s section.",
+			MessageMetaTagKeep:       "To stay verified, don't remove the meta tag even after verification succeeds.",
+			MessageJsonFileCreate:    "Create a JSON file named %s with the content:",
+			MessageXmlFileCreate:     "Create an XML file named %s with the content:",
+			MessageTextFileCreate:    "Create a file named %s with the content:",
+			MessageFileUploadRoot:    "Upload it to the root of your site.",
+			MessageFileUploadPath:    "Upload it to your site so that it is served at %s.",
+			MessageHttpHeaderAdd:     "Configure your web server or CDN to add the following response header to your site's home page:",
+			MessageHttpHeaderKeep:    "To stay verified, don't remove the header even after verification succeeds.",
+			MessageTxtRecordCreate:   "Create a %s record with the name %s and the following content:",
+			MessageCnameRecordCreate: "Add a %s (alias) record with the name %s and the following value:",
+			MessageDnsHostCreate:     "In %s, create a %s record for %s with the following fields:",
+			MessageDnsHostEmptyName:  "leave it empty",
+		},
+		"fr": {
+			MessageMetaTagHomePage:   "Copiez et collez la balise %s dans la page d'accueil de votre site.",
+			MessageMetaTagPage:       "Copiez et collez la balise %s dans la page %s de votre site.",
+			MessageMetaTagHead:       "Elle doit être placée dans la section %s, avant la première section %s.",
+			MessageMetaTagKeep:       "Pour rester vérifié, ne supprimez pas la balise meta, même après la réussite de la vérification.",
+			MessageJsonFileCreate:    "Créez un fichier JSON nommé %s avec le contenu :",
+			MessageXmlFileCreate:     "Créez un fichier XML nommé %s avec le contenu :",
+			MessageTextFileCreate:    "Créez un fichier nommé %s avec le contenu :",
+			MessageFileUploadRoot:    "Téléversez-le à la racine de votre site.",
+			MessageFileUploadPath:    "Téléversez-le sur votre site afin qu'il soit accessible à l'adresse %s.",
+			MessageHttpHeaderAdd:     "Configurez votre serveur web ou votre CDN pour ajouter l'en-tête de réponse suivant à la page d'accueil de votre site :",
+			MessageHttpHeaderKeep:    "Pour rester vérifié, ne supprimez pas l'en-tête, même après la réussite de la vérification.",
+			MessageTxtRecordCreate:   "Créez un enregistrement %s nommé %s avec le contenu suivant :",
+			MessageCnameRecordCreate: "Ajoutez un enregistrement %s (alias) nommé %s avec la valeur suivante :",
+			MessageDnsHostCreate:     "Dans %s, créez un enregistrement %s pour %s avec les champs suivants :",
+			MessageDnsHostEmptyName:  "laissez-le vide",
+		},
+		"de": {
+			MessageMetaTagHomePage:   "Kopieren Sie das %s-Tag und fügen Sie es in die Startseite Ihrer Website ein.",
+			MessageMetaTagPage:       "Kopieren Sie das %s-Tag und fügen Sie es in die Seite %s Ihrer Website ein.",
+			MessageMetaTagHead:       "Es muss im Abschnitt %s stehen, vor dem ersten Abschnitt %s.",
+			MessageMetaTagKeep:       "Entfernen Sie das Meta-Tag auch nach erfolgreicher Bestätigung nicht, damit Ihre Website bestätigt bleibt.",
+			MessageJsonFileCreate:    "Erstellen Sie eine JSON-Datei mit dem Namen %s und folgendem Inhalt:",
+			MessageXmlFileCreate:     "Erstellen Sie eine XML-Datei mit dem Namen %s und folgendem Inhalt:",
+			MessageTextFileCreate:    "Erstellen Sie eine Datei mit dem Namen %s und folgendem Inhalt:",
+			MessageFileUploadRoot:    "Laden Sie sie in das Stammverzeichnis Ihrer Website hoch.",
+			MessageFileUploadPath:    "Laden Sie sie so auf Ihre Website hoch, dass sie unter %s abrufbar ist.",
+			MessageHttpHeaderAdd:     "Konfigurieren Sie Ihren Webserver oder Ihr CDN so, dass der Startseite Ihrer Website der folgende Antwort-Header hinzugefügt wird:",
+			MessageHttpHeaderKeep:    "Entfernen Sie den Header auch nach erfolgreicher Bestätigung nicht, damit Ihre Website bestätigt bleibt.",
+			MessageTxtRecordCreate:   "Erstellen Sie einen %s-Eintrag mit dem Namen %s und folgendem Inhalt:",
+			MessageCnameRecordCreate: "Fügen Sie einen %s-Eintrag (Alias) mit dem Namen %s und folgendem Wert hinzu:",
+			MessageDnsHostCreate:     "Erstellen Sie in %s einen %s-Eintrag für %s mit den folgenden Feldern:",
+			MessageDnsHostEmptyName:  "leer lassen",
+		},
+		"ja": {
+			MessageMetaTagHomePage:   "%s タグをコピーして、サイトのホームページに貼り付けてください。",
+			MessageMetaTagPage:       "%s タグをコピーして、サイトの %s ページに貼り付けてください。",
+			MessageMetaTagHead:       "最初の %[2]s セクションより前の %[1]s セクション内に配置してください。",
+			MessageMetaTagKeep:       "確認が完了した後も、確認済みの状態を維持するためにメタタグを削除しないでください。",
+			MessageJsonFileCreate:    "次の内容で %s という名前の JSON ファイルを作成してください:",
+			MessageXmlFileCreate:     "次の内容で %s という名前の XML ファイルを作成してください:",
+			MessageTextFileCreate:    "次の内容で %s という名前のファイルを作成してください:",
+			MessageFileUploadRoot:    "サイトのルートにアップロードしてください。",
+			MessageFileUploadPath:    "%s で配信されるようにサイトにアップロードしてください。",
+			MessageHttpHeaderAdd:     "ウェブサーバーまたは CDN を設定して、サイトのホームページに次のレスポンスヘッダーを追加してください:",
+			MessageHttpHeaderKeep:    "確認が完了した後も、確認済みの状態を維持するためにヘッダーを削除しないでください。",
+			MessageTxtRecordCreate:   "名前が %[2]s で次の内容の %[1]s レコードを作成してください:",
+			MessageCnameRecordCreate: "名前が %[2]s で次の値の %[1]s (エイリアス) レコードを追加してください:",
+			MessageDnsHostCreate:     "%[1]s で、%[3]s の %[2]s レコードを次のフィールドで作成してください:",
+			MessageDnsHostEmptyName:  "空欄のままにしてください",
+		},
+	}
+)
+
+// RegisterCatalog adds the translations of catalog to the language, e.g. to support a new language
+// or to override some sentences of a bundled language (including the default English sentences).
+// The sentences not defined in catalog are left untouched.
+// A sentence cannot reference more values than the English sentence of the same key.
+//
+// Example:
+//
+//	err := domainverifier.RegisterCatalog("fr-CA", domainverifier.Catalog{
+//		domainverifier.MessageFileUploadRoot: "Téléchargez-le à la racine de votre site.",
+//	})
+func RegisterCatalog(language string, catalog Catalog) error {
+	language = normalizeLanguage(language)
+	if language == "" {
+		return InvalidLanguageError
+	}
+
+	catalogsMutex.Lock()
+	defer catalogsMutex.Unlock()
+
+	for key, message := range catalog {
+		if english, exists := catalogs[DefaultLanguage][key]; exists {
+			if count := placeholderCount(message); count > placeholderCount(english) {
+				return fmt.Errorf("message %s references %d values, at most %d are available", key, count, placeholderCount(english))
+			}
+		}
+	}
+
+	existing := catalogs[language]
+	if existing == nil {
+		existing = make(Catalog, len(catalog))
+		catalogs[language] = existing
+	}
+	for key, message := range catalog {
+		existing[key] = message
+	}
+	return nil
+}
+
+// Translate returns the sentence of the key in the first of the languages having a translation.
+// Each language falls back to its parent (e.g. fr-CA to fr), and DefaultLanguage is tried last.
+// It returns an empty string if the key is unknown.
+func Translate(key MessageKey, languages ...string) string {
+	catalogsMutex.RLock()
+	defer catalogsMutex.RUnlock()
+
+	for _, language := range languageFallbacks(languages) {
+		if message, exists := catalogs[language][key]; exists {
+			return message
+		}
+	}
+	return ""
+}
+
+// Languages returns the language tags having a catalog, sorted.
+func Languages() []string {
+	catalogsMutex.RLock()
+	defer catalogsMutex.RUnlock()
+
+	languages := make([]string, 0, len(catalogs))
+	for language := range catalogs {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// languageFallbacks returns the languages to try in order: each requested language followed by its parents,
+// then DefaultLanguage. Tags are normalized, so fr_FR and FR-fr are both tried as fr-fr then fr.
+func languageFallbacks(languages []string) []string {
+	var fallbacks []string
+	seen := make(map[string]bool)
+	add := func(language string) {
+		if language != "" && !seen[language] {
+			seen[language] = true
+			fallbacks = append(fallbacks, language)
+		}
+	}
+
+	for _, language := range languages {
+		language = normalizeLanguage(language)
+		for language != "" {
+			add(language)
+			index := strings.LastIndex(language, "-")
+			if index < 0 {
+				break
+			}
+			language = language[:index]
+		}
+	}
+	add(DefaultLanguage)
+	return fallbacks
+}
+
+// normalizeLanguage lowercases a BCP 47 language tag and uses hyphens as separators.
+func normalizeLanguage(language string) string {
+	return strings.Trim(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(language), "_", "-")), "-")
+}
@@ -0,0 +1,156 @@
+package domainverifier
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLanguageFallbacks(t *testing.T) {
+	tests := []struct {
+		name      string
+		languages []string
+		want      []string
+	}{
+		{"no language", nil, []string{"en"}},
+		{"region", []string{"fr_CA"}, []string{"fr-ca", "fr", "en"}},
+		{"several languages", []string{"de-DE", "ja", "EN"}, []string{"de-de", "de", "ja", "en"}},
+		{"script and region", []string{"zh-Hant-TW"}, []string{"zh-hant-tw", "zh-hant", "zh", "en"}},
+		{"empty language", []string{" "}, []string{"en"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := languageFallbacks(tt.languages); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("languageFallbacks() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	if err := RegisterCatalog("x-test", Catalog{MessageFileUploadRoot: "Put it at the root, please."}); err != nil {
+		t.Fatalf("RegisterCatalog() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		key       MessageKey
+		languages []string
+		want      string
+	}{
+		{"bundled language", MessageFileUploadRoot, []string{"fr"}, "Téléversez-le à la racine de votre site."},
+		{"parent language", MessageFileUploadRoot, []string{"de-AT"}, "Laden Sie sie in das Stammverzeichnis Ihrer Website hoch."},
+		{"next requested language", MessageFileUploadRoot, []string{"it", "ja"}, "サイトのルートにアップロードしてください。"},
+		{"default language", MessageFileUploadRoot, []string{"it"}, "Upload it to the root of your site."},
+		{"registered language", MessageFileUploadRoot, []string{"x-test"}, "Put it at the root, please."},
+		{"registered language falls back per key", MessageFileUploadPath, []string{"x-test", "fr"},
+			"Téléversez-le sur votre site afin qu'il soit accessible à l'adresse %s."},
+		{"unknown key", MessageKey("unknown"), []string{"fr"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Translate(tt.key, tt.languages...); got != tt.want {
+				t.Errorf("Translate() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterCatalog(t *testing.T) {
+	original := Translate(MessageMetaTagKeep, "fr")
+	defer func() {
+		_ = RegisterCatalog("fr", Catalog{MessageMetaTagKeep: original})
+	}()
+
+	if err := RegisterCatalog("FR", Catalog{MessageMetaTagKeep: "Gardez la balise."}); err != nil {
+		t.Fatalf("RegisterCatalog() error = %v", err)
+	}
+	if got := Translate(MessageMetaTagKeep, "fr"); got != "Gardez la balise." {
+		t.Errorf("expected the overridden sentence, got: %v", got)
+	}
+	if got := Translate(MessageMetaTagHead, "fr"); !strings.HasPrefix(got, "Elle doit") {
+		t.Errorf("expected the other sentences to be kept, got: %v", got)
+	}
+
+	if err := RegisterCatalog("", Catalog{}); err != InvalidLanguageError {
+		t.Errorf("expected: %v, got: %v", InvalidLanguageError, err)
+	}
+	if err := RegisterCatalog("x-test", Catalog{MessageFileUploadPath: "Serve it at %s or %[2]s."}); err == nil {
+		t.Errorf("expected an error for a sentence referencing too many values")
+	}
+}
+
+func TestRegisterCatalogDefaultAction(t *testing.T) {
+	original := Translate(MessageHttpHeaderKeep, DefaultLanguage)
+	defer func() {
+		_ = RegisterCatalog(DefaultLanguage, Catalog{MessageHttpHeaderKeep: original})
+	}()
+
+	if err := RegisterCatalog(DefaultLanguage, Catalog{MessageHttpHeaderKeep: "Keep the header."}); err != nil {
+		t.Fatalf("RegisterCatalog() error = %v", err)
+	}
+	instruction, err := GenerateHttpHeader("myapp")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !strings.HasSuffix(instruction.Action, "\n* Keep the header.") {
+		t.Errorf("expected the overridden sentence in the action, got: %v", instruction.Action)
+	}
+}
+
+func TestScanPlaceholders(t *testing.T) {
+	tests := []struct {
+		text      string
+		wantFill  string
+		wantCount int
+	}{
+		{"Create a %s record named %s.", "Create a <A> record named <B>.", 2},
+		{"名前が %[2]s で %[1]s レコード", "名前が <B> で <A> レコード", 2},
+		{"%[2]s then %s", "<B> then ", 3},
+		{"100% sure, %s", "100% sure, <A>", 1},
+		{"no value", "no value", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			step := Step{Text: tt.text, Values: []string{"A", "B"}}
+			got := fillValues(step, noEscape, func(s string) string { return "<" + s + ">" })
+			if got != tt.wantFill {
+				t.Errorf("fillValues() got = %v, want %v", got, tt.wantFill)
+			}
+			if count := placeholderCount(tt.text); count != tt.wantCount {
+				t.Errorf("placeholderCount() got = %v, want %v", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestRenderLocalized(t *testing.T) {
+	instruction := &DnsRecordInstruction{Method: MethodTxtRecord, HostName: "_myapp-challenge", Record: "myapp=1234"}
+
+	got, err := RenderLocalized(instruction, RenderText, "ja-JP")
+	if err != nil {
+		t.Fatalf("RenderLocalized() error = %v", err)
+	}
+	want := "1. 名前が _myapp-challenge で次の内容の TXT レコードを作成してください:\n   myapp=1234\n"
+	if got != want {
+		t.Errorf("RenderLocalized() got = %v, want %v", got, want)
+	}
+
+	got, _ = RenderLocalized(instruction, RenderText, "it")
+	if !strings.HasPrefix(got, "1. Create a TXT record with the name _myapp-challenge") {
+		t.Errorf("expected the English fallback, got: %v", got)
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	languages := Languages()
+	if !sort.StringsAreSorted(languages) {
+		t.Errorf("expected sorted languages, got: %v", languages)
+	}
+	for _, language := range []string{"de", "en", "fr", "ja"} {
+		if i := sort.SearchStrings(languages, language); i == len(languages) || languages[i] != language {
+			t.Errorf("expected %s among: %v", language, languages)
+		}
+	}
+}
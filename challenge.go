@@ -0,0 +1,182 @@
+package domainverifier
+
+import (
+	"context"
+	"errors"
+	"github.com/egbakou/domainverifier/config"
+	"strings"
+	"time"
+)
+
+// ChallengeStatus is the status of a Challenge.
+type ChallengeStatus string
+
+const (
+	ChallengePending  ChallengeStatus = "pending"
+	ChallengeVerified ChallengeStatus = "verified"
+	ChallengeFailed   ChallengeStatus = "failed"
+	ChallengeExpired  ChallengeStatus = "expired"
+	ChallengeRevoked  ChallengeStatus = "revoked"
+	ChallengeLost     ChallengeStatus = "lost" // the verification was lost on re-check, see Scheduler
+)
+
+// InvalidChallengeError indicates that a challenge has no ID, an invalid domain or no method configs.
+var InvalidChallengeError = errors.New("invalid challenge, it requires an ID, a valid domain and method configs")
+
+// Challenge is a verification issued for a domain, as recorded by a ChallengeStore.
+// Methods holds the configs, including the issued codes, of the methods that can verify the domain:
+// only the config of Method when it is set, otherwise any of them (see InstructionBundle.Verify).
+type Challenge struct {
+	ID        string          `json:"id"`
+	Domain    string          `json:"domain"`
+	Account   string          `json:"account,omitempty"` // optional account the domain is verified for
+	Method    Method          `json:"method,omitempty"`
+	Methods   *config.Methods `json:"methods"`
+	Status    ChallengeStatus `json:"status"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Attempts  int             `json:"attempts,omitempty"`   // verification attempts, see LifecycleManager.Attempt
+	LastError string          `json:"last_error,omitempty"` // error of the last failed attempt
+	History   []Transition    `json:"history,omitempty"`    // status transitions, oldest first
+	CheckedAt time.Time       `json:"checked_at"`           // time of the last re-check, see LifecycleManager.Recheck
+	// RecheckFailures is the number of consecutive failed re-checks of a verified challenge.
+	RecheckFailures int `json:"recheck_failures,omitempty"`
+}
+
+// Transition is a change of the status of a challenge.
+type Transition struct {
+	From   ChallengeStatus `json:"from"`
+	To     ChallengeStatus `json:"to"`
+	At     time.Time       `json:"at"`
+	Reason string          `json:"reason,omitempty"`
+}
+
+// Check verifies the ownership of the domain of the challenge and returns the method that verified it.
+// dnsResolver is the DNS server used by the TXT and CNAME methods (dnsresolver.CloudflareDNS when empty).
+// The status of the challenge is left untouched, see LifecycleManager.Attempt.
+func (c *Challenge) Check(dnsResolver string) (Method, bool, error) {
+	return c.check(context.Background(), checkOptions{dnsResolver: dnsResolver})
+}
+
+// check is Check with the context and the options of checkMethod.
+func (c *Challenge) check(ctx context.Context, options checkOptions) (Method, bool, error) {
+	if c == nil || c.Methods == nil {
+		return "", false, InvalidChallengeError
+	}
+	if c.Method == "" {
+		return verifyMethods(ctx, c.Methods, c.Domain, options)
+	}
+
+	verified, err := checkMethod(ctx, c.Method, c.Methods, c.Domain, options)
+	if !verified {
+		return "", false, err
+	}
+	return c.Method, true, nil
+}
+
+func (c *Challenge) validate() error {
+	if c == nil || strings.TrimSpace(c.ID) == "" || c.Methods == nil || !IsValidDomainName(c.Domain) {
+		return InvalidChallengeError
+	}
+	return nil
+}
+
+// ChallengeIssuer generates the instructions of the verification methods, like GenerateAllFromConfig,
+// and records the issued challenges in its Store. Issue is a shorthand for GenerateAllFromConfig
+// with BundleOptions.Store; IssueMethod records a challenge verified by a single method.
+type ChallengeIssuer struct {
+	Store ChallengeStore
+}
+
+// NewChallengeIssuer returns an issuer recording the challenges in the store.
+func NewChallengeIssuer(store ChallengeStore) *ChallengeIssuer {
+	return &ChallengeIssuer{Store: store}
+}
+
+// Issue generates the instructions of every method configured in methods for the domain,
+// and records a pending challenge verified by any of them.
+//
+// Example:
+//
+//	issuer := domainverifier.NewChallengeIssuer(domainverifier.NewMemoryChallengeStore())
+//	challenge, bundle, err := issuer.Issue("website.com", "account-42", methods, nil)
+//	// later
+//	method, verified, err := challenge.Check(dnsresolver.CloudflareDNS)
+func (i *ChallengeIssuer) Issue(domain, account string, methods *config.Methods, options *BundleOptions) (*Challenge, *InstructionBundle, error) {
+	return i.issue(domain, account, "", methods, options)
+}
+
+// IssueMethod generates the instructions of a single method configured in methods for the domain,
+// and records a pending challenge verified by this method only.
+// UnknownMethodError is returned when the method is not configured.
+func (i *ChallengeIssuer) IssueMethod(domain, account string, method Method, methods *config.Methods) (*Challenge, *InstructionBundle, error) {
+	selected, err := selectMethod(method, methods)
+	if err != nil {
+		return nil, nil, err
+	}
+	return i.issue(domain, account, method, selected, nil)
+}
+
+func (i *ChallengeIssuer) issue(domain, account string, method Method, methods *config.Methods,
+	options *BundleOptions) (*Challenge, *InstructionBundle, error) {
+	if i == nil || i.Store == nil {
+		return nil, nil, errors.New("challenge store cannot be nil")
+	}
+	if !IsValidDomainName(domain) {
+		return nil, nil, InvalidDomainError
+	}
+
+	bundle, err := GenerateAllFromConfig(methods, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if bundle.Challenge, err = recordChallenge(i.Store, domain, account, method, bundle.Methods); err != nil {
+		return nil, nil, err
+	}
+	return bundle.Challenge, bundle, nil
+}
+
+// recordChallenge records in the store a pending challenge of the domain, verified by the method
+// or by any of the methods when it is empty.
+func recordChallenge(store ChallengeStore, domain, account string, method Method, methods *config.Methods) (*Challenge, error) {
+	challenge := &Challenge{
+		Domain:  domain,
+		Account: account,
+		Method:  method,
+		Methods: methods,
+		Status:  ChallengePending,
+	}
+	if err := store.Create(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// selectMethod returns a copy of methods holding only the config of the method.
+func selectMethod(method Method, methods *config.Methods) (*config.Methods, error) {
+	if methods == nil {
+		return nil, UnknownMethodError
+	}
+
+	selected := &config.Methods{}
+	switch {
+	case method == MethodHtmlMeta && methods.HtmlMeta != nil:
+		selected.HtmlMeta = methods.HtmlMeta
+	case method == MethodJson && methods.Json != nil:
+		selected.Json = methods.Json
+	case method == MethodXml && methods.Xml != nil:
+		selected.Xml = methods.Xml
+	case method == MethodTextFile && methods.TextFile != nil:
+		selected.TextFile = methods.TextFile
+	case method == MethodHttpHeader && methods.HttpHeader != nil:
+		selected.HttpHeader = methods.HttpHeader
+	case method == MethodTxtRecord && methods.TxtRecord != nil:
+		selected.TxtRecord = methods.TxtRecord
+	case method == MethodCnameRecord && methods.CnameRecord != nil:
+		selected.CnameRecord = methods.CnameRecord
+	default:
+		return nil, UnknownMethodError
+	}
+	return selected, nil
+}
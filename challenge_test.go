@@ -0,0 +1,90 @@
+package domainverifier
+
+import (
+	"fmt"
+	"github.com/egbakou/domainverifier/config"
+	"testing"
+)
+
+func TestChallengeIssuer(t *testing.T) {
+	methods := &config.Methods{
+		HttpHeader: &config.HttpHeaderGenerator{HeaderName: "X-Myapp-Verification"},
+		TxtRecord:  &config.TxtRecordGenerator{HostName: "_myapp-challenge", RecordAttribute: "myapp-site-verification"},
+	}
+	store := NewMemoryChallengeStore()
+	issuer := NewChallengeIssuer(store)
+
+	challenge, bundle, err := issuer.Issue("website.com", "alice", methods, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bundle.TxtRecord == nil || bundle.HttpHeader == nil || challenge.Method != "" {
+		t.Errorf("expected the instructions of every method, got: %+v", bundle)
+	}
+	stored, err := store.Get(challenge.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored.Status != ChallengePending || stored.Account != "alice" ||
+		stored.Methods.TxtRecord.RecordAttributeValue != bundle.Methods.TxtRecord.RecordAttributeValue {
+		t.Errorf("unexpected stored challenge: %+v", stored)
+	}
+
+	challenge, bundle, err = issuer.IssueMethod("website.com", "", MethodTxtRecord, methods)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bundle.HttpHeader != nil || challenge.Methods.HttpHeader != nil || challenge.Method != MethodTxtRecord {
+		t.Errorf("expected the TXT record method only, got: %+v", challenge)
+	}
+	if methods.TxtRecord.RecordAttributeValue != "" {
+		t.Errorf("expected the configs to be left untouched")
+	}
+
+	if _, _, err := issuer.IssueMethod("website.com", "", MethodXml, methods); err != UnknownMethodError {
+		t.Errorf("expected: %v, got: %v", UnknownMethodError, err)
+	}
+	if _, _, err := issuer.Issue("not a domain", "", methods, nil); err != InvalidDomainError {
+		t.Errorf("expected: %v, got: %v", InvalidDomainError, err)
+	}
+	if found, _ := store.FindByDomain("website.com", ""); len(found) != 2 {
+		t.Errorf("expected: %v, got: %v", 2, len(found))
+	}
+}
+
+func TestChallengeCheck(t *testing.T) {
+	challenge := &Challenge{
+		Domain: "website.com",
+		Methods: &config.Methods{
+			TxtRecord:   &config.TxtRecordGenerator{HostName: "_myapp-challenge", RecordAttribute: "myapp", RecordAttributeValue: "1234"},
+			CnameRecord: &config.CnameRecordGenerator{RecordName: "abcd", RecordTarget: "verify.myapp.com"},
+		},
+	}
+	resolver := startTestDNSServer(t, map[string][]string{
+		"_myapp-challenge.website.com.": {`_myapp-challenge.website.com. 60 IN TXT "myapp=1234"`},
+	})
+
+	tests := []struct {
+		method     Method
+		wantMethod Method
+		want       bool
+	}{
+		{"", MethodTxtRecord, true},
+		{MethodTxtRecord, MethodTxtRecord, true},
+		{MethodCnameRecord, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("method %q", tt.method), func(t *testing.T) {
+			c := *challenge
+			c.Method = tt.method
+			method, got, _ := c.Check(resolver)
+			if got != tt.want || method != tt.wantMethod {
+				t.Errorf("expected: %v %v, got: %v %v", tt.wantMethod, tt.want, method, got)
+			}
+		})
+	}
+
+	if _, _, err := (&Challenge{Domain: "website.com"}).Check(resolver); err != InvalidChallengeError {
+		t.Errorf("expected: %v, got: %v", InvalidChallengeError, err)
+	}
+}
@@ -7,12 +7,31 @@ import (
 	"strings"
 )
 
+// InvalidConfigError indicates that the config is nil.
+// The other validation errors are returned as ValidationErrors.
 var InvalidConfigError = errors.New("config cannot be nil")
 
+// FileNamePlaceholder is the placeholder replaced by the file name in a file Path template
+// (e.g. /.well-known/{fileName}).
+const FileNamePlaceholder = "{fileName}"
+
+// ActionOptions customizes the Action of the generated instructions. It is embedded in every generator config.
+// ActionTemplate is a text/template, or an html/template when HtmlTemplate is true, executed with a
+// domainverifier.TemplateData. The default Action is used when it is empty.
+// Language translates the default Action with the catalogs of the domainverifier package (see RegisterCatalog).
+type ActionOptions struct {
+	ActionTemplate string `json:"action_template,omitempty" yaml:"action_template,omitempty"`
+	HtmlTemplate   bool   `json:"html_template,omitempty" yaml:"html_template,omitempty"`
+	Domain         string `json:"domain,omitempty" yaml:"domain,omitempty"`     // optional domain the instructions are issued for, exposed to the template
+	Language       string `json:"language,omitempty" yaml:"language,omitempty"` // optional language tag of the default Action (e.g. fr-CA)
+}
+
 // HmlMetaTagGenerator is the required config to generate HTML Meta verification method instructions.
 type HmlMetaTagGenerator struct {
-	TagName string
-	Code    string
+	TagName       string `json:"tag_name" yaml:"tag_name"`
+	Code          string `json:"code,omitempty" yaml:"code,omitempty"`
+	Path          string `json:"path,omitempty" yaml:"path,omitempty"` // optional page holding the meta tag (e.g. /shop/), the home page when empty
+	ActionOptions `yaml:",inline"`
 }
 
 func (h *HmlMetaTagGenerator) Validate() error {
@@ -20,21 +39,25 @@ func (h *HmlMetaTagGenerator) Validate() error {
 		return InvalidConfigError
 	}
 
-	if strings.TrimSpace(h.TagName) == "" {
-		return fmt.Errorf("tag name cannot be empty")
-	}
-
-	if strings.TrimSpace(h.Code) == "" {
-		return fmt.Errorf("code cannot be empty")
-	}
-	return nil
+	v := &fieldValidator{}
+	v.required("TagName", "tag name", h.TagName, metaTagName)
+	v.required("Code", "code", h.Code, codeCharset)
+	v.optional("Path", "path", h.Path, pagePath)
+	v.actionOptions(h.ActionOptions)
+	return v.err()
 }
 
 // JsonGenerator is the required config to generate JSON verification method instructions.
 type JsonGenerator struct {
-	FileName  string
-	Attribute string
-	Code      string
+	FileName  string   `json:"file_name" yaml:"file_name"`
+	Attribute string   `json:"attribute" yaml:"attribute"`
+	Code      string   `json:"code,omitempty" yaml:"code,omitempty"`
+	Codes     []string `json:"codes,omitempty" yaml:"codes,omitempty"` // optional additional codes, e.g. one per account managing the domain
+	// Path is the optional location of the file on the site: either a directory prefix
+	// (e.g. /.well-known) or a path template containing FileNamePlaceholder
+	// (e.g. /.well-known/{fileName}). The file is expected at the root of the site when empty.
+	Path          string `json:"path,omitempty" yaml:"path,omitempty"`
+	ActionOptions `yaml:",inline"`
 }
 
 func (j *JsonGenerator) Validate() error {
@@ -42,25 +65,28 @@ func (j *JsonGenerator) Validate() error {
 		return InvalidConfigError
 	}
 
-	if strings.TrimSpace(j.FileName) == "" {
-		return fmt.Errorf("file name cannot be empty")
-	}
-
-	if strings.TrimSpace(j.Attribute) == "" {
-		return fmt.Errorf("attribute cannot be empty")
-	}
+	v := &fieldValidator{}
+	v.required("FileName", "file name", j.FileName, fileNameRule(j.Path))
+	v.required("Attribute", "attribute", j.Attribute, noControlCharacters)
+	v.codes(j.Code, j.Codes)
+	v.optional("Path", "path", j.Path, filePath)
+	v.actionOptions(j.ActionOptions)
+	return v.err()
+}
 
-	if strings.TrimSpace(j.Code) == "" {
-		return fmt.Errorf("code cannot be empty")
-	}
-	return nil
+// VerificationCodes returns Code, when not empty, followed by Codes.
+func (j *JsonGenerator) VerificationCodes() []string {
+	return verificationCodes(j.Code, j.Codes)
 }
 
 // XmlGenerator is the required config to generate XML verification method instructions.
 type XmlGenerator struct {
-	FileName string
-	RootName string
-	Code     string
+	FileName      string   `json:"file_name" yaml:"file_name"`
+	RootName      string   `json:"root_name" yaml:"root_name"`
+	Code          string   `json:"code,omitempty" yaml:"code,omitempty"`
+	Codes         []string `json:"codes,omitempty" yaml:"codes,omitempty"` // optional additional codes, written as repeated <code> elements
+	Path          string   `json:"path,omitempty" yaml:"path,omitempty"`   // optional directory prefix or path template, see JsonGenerator.Path
+	ActionOptions `yaml:",inline"`
 }
 
 func (x *XmlGenerator) Validate() error {
@@ -68,57 +94,110 @@ func (x *XmlGenerator) Validate() error {
 		return InvalidConfigError
 	}
 
-	if strings.TrimSpace(x.FileName) == "" {
-		return fmt.Errorf("file name cannot be empty")
-	}
-
-	if strings.TrimSpace(x.RootName) == "" {
-		return fmt.Errorf("root name cannot be empty")
-	}
+	v := &fieldValidator{}
+	v.required("FileName", "file name", x.FileName, fileNameRule(x.Path))
+	v.required("RootName", "root name", x.RootName, xmlName)
+	v.codes(x.Code, x.Codes)
+	v.optional("Path", "path", x.Path, filePath)
+	v.actionOptions(x.ActionOptions)
+	return v.err()
+}
 
-	if strings.TrimSpace(x.Code) == "" {
-		return fmt.Errorf("code cannot be empty")
-	}
-	return nil
+// VerificationCodes returns Code, when not empty, followed by Codes.
+func (x *XmlGenerator) VerificationCodes() []string {
+	return verificationCodes(x.Code, x.Codes)
 }
 
+// ToXml returns the XML document holding one <code> element per verification code.
+// The codes are escaped; RootName is expected to be valid (see Validate).
 func (x *XmlGenerator) ToXml() string {
 	var sb strings.Builder
 	sb.WriteString(xml.Header)
-	sb.WriteString(fmt.Sprintf(`<%s><code>%s</code></%s>`, x.RootName, x.Code, x.RootName))
+	sb.WriteString(fmt.Sprintf(`<%s>`, x.RootName))
+	for _, code := range x.VerificationCodes() {
+		sb.WriteString("<code>")
+		_ = xml.EscapeText(&sb, []byte(code)) // writing to a strings.Builder never fails
+		sb.WriteString("</code>")
+	}
+	sb.WriteString(fmt.Sprintf(`</%s>`, x.RootName))
 	return sb.String()
 }
 
-// TxtRecordGenerator is the required config to generate TXT record verification method instructions.
-type TxtRecordGenerator struct {
-	HostName             string // @ or the domain name to verify or unique generated code.
-	RecordAttribute      string
-	RecordAttributeValue string
+func verificationCodes(code string, codes []string) []string {
+	all := make([]string, 0, len(codes)+1)
+	if strings.TrimSpace(code) != "" {
+		all = append(all, code)
+	}
+	return append(all, codes...)
 }
 
-func (t *TxtRecordGenerator) Validate() error {
+// TextFileGenerator is the required config to generate plain-text file verification method instructions
+// (e.g. google1234.html containing "google-site-verification: google1234.html").
+type TextFileGenerator struct {
+	FileName      string `json:"file_name" yaml:"file_name"`
+	Content       string `json:"content,omitempty" yaml:"content,omitempty"`
+	Path          string `json:"path,omitempty" yaml:"path,omitempty"` // optional directory prefix or path template, see JsonGenerator.Path
+	ActionOptions `yaml:",inline"`
+}
+
+func (t *TextFileGenerator) Validate() error {
 	if t == nil {
 		return InvalidConfigError
 	}
 
-	if strings.TrimSpace(t.HostName) == "" {
-		return fmt.Errorf("host name cannot be empty")
-	}
+	v := &fieldValidator{}
+	v.required("FileName", "file name", t.FileName, fileNameRule(t.Path))
+	v.required("Content", "content", t.Content)
+	v.optional("Path", "path", t.Path, filePath)
+	v.actionOptions(t.ActionOptions)
+	return v.err()
+}
+
+// HttpHeaderGenerator is the required config to generate HTTP response header verification method instructions.
+type HttpHeaderGenerator struct {
+	HeaderName    string `json:"header_name" yaml:"header_name"`
+	Code          string `json:"code,omitempty" yaml:"code,omitempty"`
+	ActionOptions `yaml:",inline"`
+}
 
-	if strings.TrimSpace(t.RecordAttribute) == "" {
-		return fmt.Errorf("record attribute cannot be empty")
+func (h *HttpHeaderGenerator) Validate() error {
+	if h == nil {
+		return InvalidConfigError
 	}
 
-	if strings.TrimSpace(t.RecordAttributeValue) == "" {
-		return fmt.Errorf("record attribute value cannot be empty")
+	v := &fieldValidator{}
+	v.required("HeaderName", "header name", h.HeaderName, headerName)
+	v.required("Code", "code", h.Code, codeCharset)
+	v.actionOptions(h.ActionOptions)
+	return v.err()
+}
+
+// TxtRecordGenerator is the required config to generate TXT record verification method instructions.
+type TxtRecordGenerator struct {
+	HostName             string `json:"host_name" yaml:"host_name"` // @ or the domain name to verify or unique generated code.
+	RecordAttribute      string `json:"record_attribute" yaml:"record_attribute"`
+	RecordAttributeValue string `json:"record_attribute_value,omitempty" yaml:"record_attribute_value,omitempty"`
+	ActionOptions        `yaml:",inline"`
+}
+
+func (t *TxtRecordGenerator) Validate() error {
+	if t == nil {
+		return InvalidConfigError
 	}
-	return nil
+
+	v := &fieldValidator{}
+	v.required("HostName", "host name", t.HostName, hostNameOrApex)
+	v.required("RecordAttribute", "record attribute", t.RecordAttribute, recordAttribute)
+	v.required("RecordAttributeValue", "record attribute value", t.RecordAttributeValue, codeCharset)
+	v.actionOptions(t.ActionOptions)
+	return v.err()
 }
 
 // CnameRecordGenerator is the required config to generate CNAME record verification method instructions.
 type CnameRecordGenerator struct {
-	RecordName   string
-	RecordTarget string
+	RecordName    string `json:"record_name" yaml:"record_name"`
+	RecordTarget  string `json:"record_target" yaml:"record_target"`
+	ActionOptions `yaml:",inline"`
 }
 
 func (c *CnameRecordGenerator) Validate() error {
@@ -126,12 +205,9 @@ func (c *CnameRecordGenerator) Validate() error {
 		return InvalidConfigError
 	}
 
-	if strings.TrimSpace(c.RecordName) == "" {
-		return fmt.Errorf("record name cannot be empty")
-	}
-
-	if strings.TrimSpace(c.RecordTarget) == "" {
-		return fmt.Errorf("record target cannot be empty")
-	}
-	return nil
+	v := &fieldValidator{}
+	v.required("RecordName", "record name", c.RecordName, hostName)
+	v.required("RecordTarget", "record target", c.RecordTarget, hostName)
+	v.actionOptions(c.ActionOptions)
+	return v.err()
 }
@@ -53,6 +53,7 @@ func TestJsonGenerator_Validate(t *testing.T) {
 		FileName  string
 		Attribute string
 		Code      string
+		Codes     []string
 	}
 	tests := []struct {
 		name    string
@@ -92,6 +93,25 @@ func TestJsonGenerator_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid with codes only",
+			fields: fields{
+				FileName:  "test.json",
+				Attribute: "test",
+				Codes:     []string{"test1", "test2"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "blank code in codes",
+			fields: fields{
+				FileName:  "test.json",
+				Attribute: "test",
+				Code:      "test",
+				Codes:     []string{"test1", " "},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -99,6 +119,7 @@ func TestJsonGenerator_Validate(t *testing.T) {
 				FileName:  tt.fields.FileName,
 				Attribute: tt.fields.Attribute,
 				Code:      tt.fields.Code,
+				Codes:     tt.fields.Codes,
 			}
 			if err := j.Validate(); (err != nil) != tt.wantErr {
 				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
@@ -142,6 +163,24 @@ func TestXmlGenerator_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid root name",
+			fields: fields{
+				FileName: "test.xml",
+				RootName: "test><evil",
+				Code:     "test",
+			},
+			wantErr: true,
+		},
+		{
+			name: "root name starting with a digit",
+			fields: fields{
+				FileName: "test.xml",
+				RootName: "1test",
+				Code:     "test",
+			},
+			wantErr: true,
+		},
 		{
 			name: "valid",
 			fields: fields{
@@ -151,6 +190,15 @@ func TestXmlGenerator_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid root name with namespace prefix",
+			fields: fields{
+				FileName: "test.xml",
+				RootName: "my-app:site.verification_1",
+				Code:     "test",
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -171,6 +219,7 @@ func TestXmlGenerator_ToXml(t *testing.T) {
 		FileName string
 		RootName string
 		Code     string
+		Codes    []string
 	}
 	tests := []struct {
 		name   string
@@ -187,6 +236,27 @@ func TestXmlGenerator_ToXml(t *testing.T) {
 			want: `<?xml version="1.0" encoding="UTF-8"?>
 <test><code>test</code></test>`,
 		},
+		{
+			name: "multiple codes",
+			fields: fields{
+				FileName: "test.xml",
+				RootName: "test",
+				Code:     "test",
+				Codes:    []string{"test1", "test2"},
+			},
+			want: `<?xml version="1.0" encoding="UTF-8"?>
+<test><code>test</code><code>test1</code><code>test2</code></test>`,
+		},
+		{
+			name: "escaped code",
+			fields: fields{
+				FileName: "test.xml",
+				RootName: "test",
+				Code:     `"a<b&c"`,
+			},
+			want: `<?xml version="1.0" encoding="UTF-8"?>
+<test><code>&#34;a&lt;b&amp;c&#34;</code></test>`,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -194,6 +264,7 @@ func TestXmlGenerator_ToXml(t *testing.T) {
 				FileName: tt.fields.FileName,
 				RootName: tt.fields.RootName,
 				Code:     tt.fields.Code,
+				Codes:    tt.fields.Codes,
 			}
 			if got := x.ToXml(); got != tt.want {
 				t.Errorf("ToXml() = %v, want %v", got, tt.want)
@@ -202,6 +273,98 @@ func TestXmlGenerator_ToXml(t *testing.T) {
 	}
 }
 
+func TestTextFileGenerator_Validate(t *testing.T) {
+	type fields struct {
+		FileName string
+		Content  string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		wantErr bool
+	}{
+		{
+			name: "empty file name",
+			fields: fields{
+				FileName: "",
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty content",
+			fields: fields{
+				FileName: "test.html",
+				Content:  " ",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid",
+			fields: fields{
+				FileName: "test.html",
+				Content:  "test",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &TextFileGenerator{
+				FileName: tt.fields.FileName,
+				Content:  tt.fields.Content,
+			}
+			if err := f.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHttpHeaderGenerator_Validate(t *testing.T) {
+	type fields struct {
+		HeaderName string
+		Code       string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		wantErr bool
+	}{
+		{
+			name: "empty header name",
+			fields: fields{
+				HeaderName: "",
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty code",
+			fields: fields{
+				HeaderName: "X-Test",
+				Code:       "",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid",
+			fields: fields{
+				HeaderName: "X-Test",
+				Code:       "test",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &HttpHeaderGenerator{
+				HeaderName: tt.fields.HeaderName,
+				Code:       tt.fields.Code,
+			}
+			if err := h.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestTxtRecordGenerator_Validate(t1 *testing.T) {
 	type fields struct {
 		HostName             string
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Format is the format of a configuration document.
+type Format string
+
+const (
+	FormatJson Format = "json"
+	FormatYaml Format = "yaml"
+)
+
+// InvalidFormatError indicates that the configuration document format is not supported.
+var InvalidFormatError = errors.New("unsupported config format, expected json or yaml")
+
+// NoMethodError indicates that the configuration document does not describe any verification method.
+var NoMethodError = errors.New("config does not describe any verification method")
+
+// Methods is the configuration of the verification methods offered by an application.
+// Each non-nil field is a generator config, ready to be passed to the matching Generate*FromConfig function.
+//
+// The codes (Code, Content for the text file and RecordAttributeValue for the TXT record) are usually
+// left out of the document and generated per domain with useInternalCode.
+// The CNAME record name can be left out as well, GenerateAllFromConfig derives it from the code.
+//
+// Example (YAML):
+//
+//	html_meta:
+//	  tag_name: myapp-site-verification
+//	json:
+//	  file_name: myapp-site-verification.json
+//	  attribute: myapp_site_verification
+//	  path: /.well-known
+//	txt_record:
+//	  host_name: "@"
+//	  record_attribute: myapp-site-verification
+type Methods struct {
+	HtmlMeta    *HmlMetaTagGenerator  `json:"html_meta,omitempty" yaml:"html_meta,omitempty"`
+	Json        *JsonGenerator        `json:"json,omitempty" yaml:"json,omitempty"`
+	Xml         *XmlGenerator         `json:"xml,omitempty" yaml:"xml,omitempty"`
+	TextFile    *TextFileGenerator    `json:"text_file,omitempty" yaml:"text_file,omitempty"`
+	HttpHeader  *HttpHeaderGenerator  `json:"http_header,omitempty" yaml:"http_header,omitempty"`
+	TxtRecord   *TxtRecordGenerator   `json:"txt_record,omitempty" yaml:"txt_record,omitempty"`
+	CnameRecord *CnameRecordGenerator `json:"cname_record,omitempty" yaml:"cname_record,omitempty"`
+}
+
+// Validate validates every configured method with its own Validate method.
+// Missing codes and a missing CNAME record name are accepted. The fields of the returned ValidationErrors
+// are prefixed by the method name (e.g. TxtRecord.HostName).
+func (m *Methods) Validate() error {
+	if m == nil {
+		return InvalidConfigError
+	}
+
+	var errs ValidationErrors
+	var otherErr error
+	count := 0
+	add := func(method string, config interface{ Validate() error }, codeField string) {
+		count++
+		err := config.Validate()
+		if err == nil {
+			return
+		}
+		var fieldErrors ValidationErrors
+		if !errors.As(err, &fieldErrors) {
+			if otherErr == nil {
+				otherErr = fmt.Errorf("%s: %w", method, err)
+			}
+			return
+		}
+		for _, fieldError := range fieldErrors {
+			if fieldError.missing && fieldError.Field == codeField {
+				continue
+			}
+			errs = append(errs, &FieldError{
+				Field:   fmt.Sprintf("%s.%s", method, fieldError.Field),
+				Message: fmt.Sprintf("%s: %s", method, fieldError.Message),
+				missing: fieldError.missing,
+			})
+		}
+	}
+
+	if m.HtmlMeta != nil {
+		add("HtmlMeta", m.HtmlMeta, "Code")
+	}
+	if m.Json != nil {
+		add("Json", m.Json, "Code")
+	}
+	if m.Xml != nil {
+		add("Xml", m.Xml, "Code")
+	}
+	if m.TextFile != nil {
+		add("TextFile", m.TextFile, "Content")
+	}
+	if m.HttpHeader != nil {
+		add("HttpHeader", m.HttpHeader, "Code")
+	}
+	if m.TxtRecord != nil {
+		add("TxtRecord", m.TxtRecord, "RecordAttributeValue")
+	}
+	if m.CnameRecord != nil {
+		add("CnameRecord", m.CnameRecord, "RecordName")
+	}
+
+	if count == 0 {
+		return NoMethodError
+	}
+	if otherErr != nil {
+		return otherErr
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Load reads a JSON or YAML document describing the verification methods of an application
+// and validates it. Unknown fields are rejected to catch typos.
+func Load(r io.Reader, format Format) (*Methods, error) {
+	methods := &Methods{}
+	switch format {
+	case FormatJson:
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(methods); err != nil {
+			return nil, err
+		}
+	case FormatYaml:
+		decoder := yaml.NewDecoder(r)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(methods); err != nil && err != io.EOF {
+			return nil, err
+		}
+	default:
+		return nil, InvalidFormatError
+	}
+
+	if err := methods.Validate(); err != nil {
+		return nil, err
+	}
+	return methods, nil
+}
+
+// LoadFile reads and validates a configuration file.
+// The format is deduced from the file extension: .json, .yaml or .yml.
+func LoadFile(path string) (*Methods, error) {
+	var format Format
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format = FormatJson
+	case ".yaml", ".yml":
+		format = FormatYaml
+	default:
+		return nil, InvalidFormatError
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Load(file, format)
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		check   func(*Methods) bool
+		wantErr bool
+	}{
+		{
+			name: "yaml",
+			path: "testdata/methods.yaml",
+			check: func(m *Methods) bool {
+				return m.HtmlMeta.TagName == "myapp-site-verification" &&
+					m.Json.Path == "/.well-known" &&
+					m.Xml.RootName == "verification" &&
+					m.TextFile.FileName == "myapp-verification.html" &&
+					m.HttpHeader.HeaderName == "X-Myapp-Site-Verification" &&
+					m.TxtRecord.HostName == "@" &&
+					strings.HasPrefix(m.TxtRecord.ActionTemplate, "Create a TXT record named {{.HostName}}") &&
+					m.CnameRecord.RecordTarget == "verify.myapp.com"
+			},
+		},
+		{
+			name: "json",
+			path: "testdata/methods.json",
+			check: func(m *Methods) bool {
+				return m.HtmlMeta.Path == "/shop/" && m.TxtRecord.RecordAttribute == "myapp-site-verification" &&
+					m.Json == nil && m.CnameRecord == nil
+			},
+		},
+		{
+			name:    "unsupported extension",
+			path:    "testdata/methods.toml",
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			path:    "testdata/missing.json",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadFile(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != nil && !tt.check(got) {
+				t.Errorf("LoadFile() loaded unexpected methods: %+v", got)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name       string
+		document   string
+		format     Format
+		wantErr    error
+		wantFields []string
+	}{
+		{
+			name:     "valid yaml without codes",
+			document: "xml:\n  file_name: SiteAuth.xml\n  root_name: verification\n",
+			format:   FormatYaml,
+		},
+		{
+			name:     "unknown json field",
+			document: `{"txt_record": {"hostname": "@"}}`,
+			format:   FormatJson,
+			wantErr:  errors.New("unknown field"),
+		},
+		{
+			name:     "unknown yaml field",
+			document: "txt_record:\n  hostname: \"@\"\n",
+			format:   FormatYaml,
+			wantErr:  errors.New("not found"),
+		},
+		{
+			name:     "empty document",
+			document: "",
+			format:   FormatYaml,
+			wantErr:  NoMethodError,
+		},
+		{
+			name:     "unsupported format",
+			document: "{}",
+			format:   Format("toml"),
+			wantErr:  InvalidFormatError,
+		},
+		{
+			name:       "invalid action template",
+			document:   `{"http_header": {"header_name": "X-Myapp", "action_template": "{{.Code"}}`,
+			format:     FormatJson,
+			wantFields: []string{"HttpHeader.ActionTemplate"},
+		},
+		{
+			name:     "cname record without record name",
+			document: `{"cname_record": {"record_target": "verify.myapp.com"}}`,
+			format:   FormatJson,
+		},
+		{
+			name: "invalid fields",
+			document: `{
+				"txt_record": {"host_name": "foo bar", "record_attribute": "myapp"},
+				"cname_record": {"record_name": "verify", "record_target": "not a host"}
+			}`,
+			format:     FormatJson,
+			wantFields: []string{"TxtRecord.HostName", "CnameRecord.RecordTarget"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(strings.NewReader(tt.document), tt.format)
+			if tt.wantErr == nil && tt.wantFields == nil {
+				if err != nil {
+					t.Errorf("Load() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Load() expected an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) && !strings.Contains(err.Error(), tt.wantErr.Error()) {
+				t.Errorf("Load() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var validationErrors ValidationErrors
+			if tt.wantFields != nil && errors.As(err, &validationErrors) {
+				if len(validationErrors) != len(tt.wantFields) {
+					t.Fatalf("Load() error = %v, want fields %v", err, tt.wantFields)
+				}
+				for i, field := range tt.wantFields {
+					if validationErrors[i].Field != field {
+						t.Errorf("error %d is on field %s, want %s", i, validationErrors[i].Field, field)
+					}
+				}
+			}
+		})
+	}
+}
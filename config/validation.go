@@ -0,0 +1,338 @@
+package config
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"net/url"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const (
+	maxHostNameLength = 253
+	maxLabelLength    = 63
+	maxFileNameLength = 255
+	maxTagNameLength  = 128
+	maxCodeLength     = 255
+	apexHostName      = "@"
+)
+
+// FieldError describes an invalid field of a generator config.
+type FieldError struct {
+	Field   string // name of the struct field, e.g. HostName or Codes[1]
+	Message string
+	missing bool // the field is blank
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors aggregates every FieldError found while validating a generator config.
+type ValidationErrors []*FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, fieldError := range v {
+		messages[i] = fieldError.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// rule checks the value of a field and returns an error message, or an empty string if the value is valid.
+type rule func(label, value string) string
+
+// fieldValidator collects the errors of the fields of a config.
+type fieldValidator struct {
+	errs ValidationErrors
+}
+
+// required checks that the field is not blank, then applies the rules until one fails.
+func (v *fieldValidator) required(field, label, value string, rules ...rule) {
+	if strings.TrimSpace(value) == "" {
+		v.addMissing(field, fmt.Sprintf("%s cannot be empty", label))
+		return
+	}
+	v.optional(field, label, value, rules...)
+}
+
+// optional applies the rules until one fails, unless the field is blank.
+func (v *fieldValidator) optional(field, label, value string, rules ...rule) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+	for _, r := range rules {
+		if message := r(label, value); message != "" {
+			v.add(field, message)
+			return
+		}
+	}
+}
+
+// codes checks that at least one code is provided and that every code is valid.
+func (v *fieldValidator) codes(code string, codes []string) {
+	if strings.TrimSpace(code) == "" && len(codes) == 0 {
+		v.addMissing("Code", "code cannot be empty")
+		return
+	}
+
+	v.optional("Code", "code", code, codeCharset)
+	for i, c := range codes {
+		if strings.TrimSpace(c) == "" {
+			v.add(fmt.Sprintf("Codes[%d]", i), "codes cannot contain an empty code")
+			continue
+		}
+		v.optional(fmt.Sprintf("Codes[%d]", i), "code", c, codeCharset)
+	}
+}
+
+// actionOptions checks that the action template can be parsed, that the domain is a host name
+// and that the language is a language tag.
+func (v *fieldValidator) actionOptions(o ActionOptions) {
+	if o.HtmlTemplate {
+		v.optional("ActionTemplate", "action template", o.ActionTemplate, htmlActionTemplate)
+	} else {
+		v.optional("ActionTemplate", "action template", o.ActionTemplate, textActionTemplate)
+	}
+	v.optional("Domain", "domain", o.Domain, hostName)
+	v.optional("Language", "language", o.Language, languageTag)
+}
+
+func (v *fieldValidator) add(field, message string) {
+	v.errs = append(v.errs, &FieldError{Field: field, Message: message})
+}
+
+func (v *fieldValidator) addMissing(field, message string) {
+	v.errs = append(v.errs, &FieldError{Field: field, Message: message, missing: true})
+}
+
+// err returns the collected errors, or nil when there are none.
+func (v *fieldValidator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// codeCharset accepts the characters of the K-Sortable Globally Unique IDs and of the
+// verification codes of the common providers: letters, digits and . _ ~ + / = : -
+func codeCharset(label, value string) string {
+	if len(value) > maxCodeLength {
+		return fmt.Sprintf("%s cannot be longer than %d characters", label, maxCodeLength)
+	}
+	for _, r := range value {
+		if !isAsciiAlphanumeric(r) && !strings.ContainsRune("._~+/=:-", r) {
+			return fmt.Sprintf("%s %q contains the invalid character %q", label, value, r)
+		}
+	}
+	return ""
+}
+
+// metaTagName accepts printable characters, except quotes and angle brackets.
+func metaTagName(label, value string) string {
+	if len(value) > maxTagNameLength {
+		return fmt.Sprintf("%s cannot be longer than %d characters", label, maxTagNameLength)
+	}
+	for _, r := range value {
+		if !unicode.IsPrint(r) || strings.ContainsRune(`"'<>`, r) {
+			return fmt.Sprintf("%s %q contains the invalid character %q", label, value, r)
+		}
+	}
+	return ""
+}
+
+// headerName accepts the token characters of RFC 7230.
+func headerName(label, value string) string {
+	for _, r := range value {
+		if !isAsciiAlphanumeric(r) && !strings.ContainsRune("!#$%&'*+-.^_`|~", r) {
+			return fmt.Sprintf("%s %q contains the invalid character %q", label, value, r)
+		}
+	}
+	return ""
+}
+
+// xmlName accepts the legal XML element names.
+func xmlName(label, value string) string {
+	if !isValidXmlName(value) {
+		return fmt.Sprintf("%s %q is not a valid XML element name", label, value)
+	}
+	return ""
+}
+
+// isValidXmlName reports whether name is a legal XML element name:
+// a letter or an underscore followed by letters, digits, hyphens, underscores and periods.
+// A single colon is accepted as namespace prefix separator, between a valid prefix and a valid local name.
+func isValidXmlName(name string) bool {
+	if prefix, local, qualified := strings.Cut(name, ":"); qualified {
+		return isValidXmlNCName(prefix) && isValidXmlNCName(local)
+	}
+	return isValidXmlNCName(name)
+}
+
+// isValidXmlNCName reports whether name is a legal XML name without colon.
+func isValidXmlNCName(name string) bool {
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r), r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+// recordAttribute accepts printable characters except whitespace, quotes and the = separator.
+func recordAttribute(label, value string) string {
+	for _, r := range value {
+		if !unicode.IsPrint(r) || unicode.IsSpace(r) || strings.ContainsRune(`"=`, r) {
+			return fmt.Sprintf("%s %q contains the invalid character %q", label, value, r)
+		}
+	}
+	return ""
+}
+
+// hostName accepts a DNS host name, relative or fully qualified: labels of 1 to 63 letters,
+// digits, hyphens and underscores, not starting or ending with a hyphen, 253 characters at most.
+func hostName(label, value string) string {
+	name := strings.TrimSuffix(value, ".")
+	if len(name) > maxHostNameLength {
+		return fmt.Sprintf("%s cannot be longer than %d characters", label, maxHostNameLength)
+	}
+
+	for _, dnsLabel := range strings.Split(name, ".") {
+		if message := checkDnsLabel(dnsLabel); message != "" {
+			return fmt.Sprintf("%s %q is not a valid host name: %s", label, value, message)
+		}
+	}
+	return ""
+}
+
+// hostNameOrApex accepts @, the apex of the domain, or a DNS host name.
+func hostNameOrApex(label, value string) string {
+	if value == apexHostName {
+		return ""
+	}
+	return hostName(label, value)
+}
+
+func checkDnsLabel(label string) string {
+	switch {
+	case label == "":
+		return "empty label"
+	case len(label) > maxLabelLength:
+		return fmt.Sprintf("label %q is longer than %d characters", label, maxLabelLength)
+	case strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-"):
+		return fmt.Sprintf("label %q starts or ends with a hyphen", label)
+	}
+
+	for _, r := range label {
+		if !isAsciiAlphanumeric(r) && r != '-' && r != '_' {
+			return fmt.Sprintf("label %q contains the invalid character %q", label, r)
+		}
+	}
+	return ""
+}
+
+// fileName accepts a bare file name: no slashes, no control characters, not . or ..
+func fileName(label, value string) string {
+	if strings.ContainsAny(value, `/\`) {
+		return fmt.Sprintf("%s %q cannot contain slashes, use Path for directories", label, value)
+	}
+	return relativeFileName(label, value)
+}
+
+// relativeFileName accepts a file name that may include sub-directories, without path traversal.
+func relativeFileName(label, value string) string {
+	if len(value) > maxFileNameLength {
+		return fmt.Sprintf("%s cannot be longer than %d characters", label, maxFileNameLength)
+	}
+	if strings.HasPrefix(value, "/") || strings.Contains(value, `\`) {
+		return fmt.Sprintf("%s %q must be relative to Path", label, value)
+	}
+	for _, segment := range strings.Split(value, "/") {
+		if segment == "." || segment == ".." {
+			return fmt.Sprintf("%s %q cannot contain . or .. segments", label, value)
+		}
+	}
+	return noControlCharacters(label, value)
+}
+
+// fileNameRule returns the file name rule of a file config: the file name may include
+// sub-directories only in path mode, i.e. when the Path of the config is set.
+func fileNameRule(path string) rule {
+	if strings.TrimSpace(path) != "" {
+		return relativeFileName
+	}
+	return fileName
+}
+
+// filePath accepts a URL path prefix or template without path traversal or whitespace.
+func filePath(label, value string) string {
+	for _, segment := range strings.Split(value, "/") {
+		if segment == ".." {
+			return fmt.Sprintf("%s %q cannot contain .. segments", label, value)
+		}
+	}
+	for _, r := range value {
+		if unicode.IsSpace(r) || strings.ContainsRune(`\?#`, r) {
+			return fmt.Sprintf("%s %q contains the invalid character %q", label, value, r)
+		}
+	}
+	return noControlCharacters(label, value)
+}
+
+// pagePath accepts a URL path starting with a slash or an absolute http(s) URL.
+func pagePath(label, value string) string {
+	if strings.HasPrefix(value, "/") {
+		return filePath(label, strings.SplitN(value, "?", 2)[0])
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Sprintf("%s %q must be a path starting with / or an http(s) URL", label, value)
+	}
+	return ""
+}
+
+// textActionTemplate accepts a text/template.
+func textActionTemplate(label, value string) string {
+	if _, err := template.New("action").Parse(value); err != nil {
+		return fmt.Sprintf("%s is invalid: %v", label, err)
+	}
+	return ""
+}
+
+// htmlActionTemplate accepts an html/template.
+func htmlActionTemplate(label, value string) string {
+	if _, err := htmltemplate.New("action").Parse(value); err != nil {
+		return fmt.Sprintf("%s is invalid: %v", label, err)
+	}
+	return ""
+}
+
+// languageTag accepts BCP 47 language tags: subtags of 1 to 8 ASCII letters and digits
+// separated by hyphens or underscores (e.g. fr, fr-CA, zh_Hant_TW).
+func languageTag(label, value string) string {
+	for _, subtag := range strings.Split(strings.ReplaceAll(value, "_", "-"), "-") {
+		invalid := strings.IndexFunc(subtag, func(r rune) bool { return !isAsciiAlphanumeric(r) }) >= 0
+		if subtag == "" || len(subtag) > 8 || invalid {
+			return fmt.Sprintf("%s %q is not a valid language tag", label, value)
+		}
+	}
+	return ""
+}
+
+func noControlCharacters(label, value string) string {
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			return fmt.Sprintf("%s %q contains a control character", label, value)
+		}
+	}
+	return ""
+}
+
+func isAsciiAlphanumeric(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
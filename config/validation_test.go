@@ -0,0 +1,111 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidationErrors(t *testing.T) {
+	txt := &TxtRecordGenerator{
+		HostName:             "foo bar",
+		RecordAttribute:      "",
+		RecordAttributeValue: "code with spaces",
+	}
+	err := txt.Validate()
+
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("Validate() error = %v, want ValidationErrors", err)
+	}
+
+	wantFields := []string{"HostName", "RecordAttribute", "RecordAttributeValue"}
+	if len(validationErrors) != len(wantFields) {
+		t.Fatalf("Validate() returned %d errors, want %d: %v", len(validationErrors), len(wantFields), err)
+	}
+	for i, field := range wantFields {
+		if validationErrors[i].Field != field {
+			t.Errorf("error %d is on field %s, want %s", i, validationErrors[i].Field, field)
+		}
+	}
+	if !strings.Contains(err.Error(), "record attribute cannot be empty") {
+		t.Errorf("unexpected message: %v", err)
+	}
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    rule
+		value   string
+		wantErr bool
+	}{
+		{"host name", hostName, "verify.myapp.com", false},
+		{"fully qualified host name", hostName, "verify.myapp.com.", false},
+		{"underscore label", hostName, "_myapp-challenge.example.com", false},
+		{"host name with space", hostName, "foo bar", true},
+		{"host name with empty label", hostName, "verify..myapp.com", true},
+		{"label starting with hyphen", hostName, "-verify.myapp.com", true},
+		{"label too long", hostName, strings.Repeat("a", 64) + ".com", true},
+		{"host name too long", hostName, strings.Repeat("a.", 127) + "com", true},
+		{"apex", hostNameOrApex, "@", false},
+		{"apex not allowed", hostName, "@", true},
+		{"code", codeCharset, "2OjBkQ6Ub0FN0L5Wn5x8bHzoCKd", false},
+		{"google code", codeCharset, "sfRybH_Mn50-a_lGoRf21hf28qx1iOucU8CsBe_hEVM", false},
+		{"code with quote", codeCharset, `abc"def`, true},
+		{"code with line break", codeCharset, "abc\r\nX-Injected: 1", true},
+		{"meta tag name", metaTagName, "msvalidate.01", false},
+		{"meta tag name with spaces", metaTagName, "my super app", false},
+		{"meta tag name with quote", metaTagName, `my"app`, true},
+		{"header name", headerName, "X-Myapp-Site-Verification", false},
+		{"header name with colon", headerName, "X-Myapp:", true},
+		{"xml name", xmlName, "verification", false},
+		{"xml name with prefix", xmlName, "ns:verification", false},
+		{"xml name starting with digit", xmlName, "1verification", true},
+		{"xml name colon only", xmlName, ":", true},
+		{"xml name empty local part", xmlName, "a:", true},
+		{"xml name two colons", xmlName, "a::b", true},
+		{"xml name empty prefix", xmlName, ":a", true},
+		{"xml name two prefixes", xmlName, "a:b:c", true},
+		{"xml name local part starting with digit", xmlName, "ns:1code", true},
+		{"record attribute", recordAttribute, "myapp-site-verification", false},
+		{"record attribute with separator", recordAttribute, "myapp=", true},
+		{"file name", fileName, "myapp-site-verification.json", false},
+		{"file name with slash", fileName, ".well-known/myapp.json", true},
+		{"file name traversal", fileName, "..", true},
+		{"relative file name", relativeFileName, "myapp/verification.json", false},
+		{"relative file name traversal", relativeFileName, "../secret.json", true},
+		{"absolute file name", relativeFileName, "/myapp.json", true},
+		{"file path prefix", filePath, "/.well-known", false},
+		{"file path template", filePath, "/.well-known/{fileName}", false},
+		{"file path traversal", filePath, "/.well-known/../../etc", true},
+		{"file path with space", filePath, "/well known", true},
+		{"page path", pagePath, "/shop/?lang=fr", false},
+		{"page url", pagePath, "https://example.com/shop/", false},
+		{"page relative path", pagePath, "shop/", true},
+		{"page ftp url", pagePath, "ftp://example.com/", true},
+		{"language", languageTag, "fr", false},
+		{"language with region", languageTag, "zh_Hant_TW", false},
+		{"language with space", languageTag, "fr CA", true},
+		{"language with empty subtag", languageTag, "fr--CA", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if message := tt.rule("value", tt.value); (message != "") != tt.wantErr {
+				t.Errorf("rule(%q) = %q, wantErr %v", tt.value, message, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFileNameRule(t *testing.T) {
+	json := &JsonGenerator{FileName: "myapp/verification.json", Attribute: "code", Code: "code"}
+	if err := json.Validate(); err == nil {
+		t.Errorf("Validate() accepted a file name with a directory without Path")
+	}
+
+	json.Path = "/.well-known"
+	if err := json.Validate(); err != nil {
+		t.Errorf("Validate() error = %v in path mode", err)
+	}
+}
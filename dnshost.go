@@ -0,0 +1,313 @@
+package domainverifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/egbakou/domainverifier/config"
+	"github.com/egbakou/domainverifier/dnsresolver"
+	"github.com/miekg/dns"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTxtStringLength is the maximum length of a character string of a TXT record.
+const maxTxtStringLength = 255
+
+// DnsHost describes how DNS records are entered in the dashboard of a DNS hosting provider,
+// whose conventions for the apex, the host names and the TXT values differ.
+type DnsHost struct {
+	ID                 string
+	Name               string
+	NameServerPatterns []string // substrings of the name servers of the provider, used by DetectDnsHost
+	ApexName           string   // host name of the apex of the domain, e.g. @ or an empty field
+	FullyQualified     bool     // host names include the domain (e.g. _myapp-challenge.website.com)
+	TrailingDot        bool     // fully qualified host names end with a dot
+	QuoteTxt           bool     // TXT values are enclosed in double quotes, 255 characters per string
+	NameLabel          string   // label of the host name field in the dashboard
+	ValueLabel         string   // label of the value field in the dashboard
+	TTLHint            string   // suggested TTL, as displayed in the dashboard
+}
+
+var (
+	DnsHostCloudflare = &DnsHost{
+		ID:                 "cloudflare",
+		Name:               "Cloudflare",
+		NameServerPatterns: []string{".ns.cloudflare.com"},
+		ApexName:           "@",
+		NameLabel:          "Name",
+		ValueLabel:         "Content",
+		TTLHint:            "Auto",
+	}
+	DnsHostRoute53 = &DnsHost{
+		ID:                 "route53",
+		Name:               "Amazon Route 53",
+		NameServerPatterns: []string{".awsdns-"},
+		ApexName:           "",
+		QuoteTxt:           true,
+		NameLabel:          "Record name",
+		ValueLabel:         "Value",
+		TTLHint:            "300",
+	}
+	DnsHostGoDaddy = &DnsHost{
+		ID:                 "godaddy",
+		Name:               "GoDaddy",
+		NameServerPatterns: []string{".domaincontrol.com"},
+		ApexName:           "@",
+		NameLabel:          "Name",
+		ValueLabel:         "Value",
+		TTLHint:            "1 Hour",
+	}
+	DnsHostNamecheap = &DnsHost{
+		ID:                 "namecheap",
+		Name:               "Namecheap",
+		NameServerPatterns: []string{".registrar-servers.com"},
+		ApexName:           "@",
+		NameLabel:          "Host",
+		ValueLabel:         "Value",
+		TTLHint:            "Automatic",
+	}
+	DnsHostGoogleDomains = &DnsHost{
+		ID:                 "google-domains",
+		Name:               "Google Domains",
+		NameServerPatterns: []string{".googledomains.com"},
+		ApexName:           "",
+		NameLabel:          "Host name",
+		ValueLabel:         "Data",
+		TTLHint:            "1 hour",
+	}
+
+	// DnsHostGeneric is used when the DNS hosting provider is unknown.
+	DnsHostGeneric = &DnsHost{
+		ID:         "generic",
+		Name:       "your DNS hosting provider",
+		ApexName:   "@",
+		NameLabel:  "Name",
+		ValueLabel: "Value",
+		TTLHint:    "the default value",
+	}
+)
+
+// dnsHosts lists the providers known by DnsHostByID and DetectDnsHost.
+var dnsHosts = []*DnsHost{DnsHostCloudflare, DnsHostRoute53, DnsHostGoDaddy, DnsHostNamecheap, DnsHostGoogleDomains}
+
+// UnknownDnsHostError indicates that no DNS hosting provider has the requested ID.
+var UnknownDnsHostError = errors.New("unknown DNS hosting provider")
+
+// DnsHostByID returns the DNS hosting provider with the ID (e.g. cloudflare, route53, godaddy, namecheap or google-domains).
+func DnsHostByID(id string) (*DnsHost, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if id == DnsHostGeneric.ID {
+		return DnsHostGeneric, nil
+	}
+	for _, host := range dnsHosts {
+		if host.ID == id {
+			return host, nil
+		}
+	}
+	return nil, UnknownDnsHostError
+}
+
+// HostName returns the host name to enter in the dashboard of the provider for a record of the domain.
+// recordName can be @ for the apex, a name relative to the domain (e.g. _myapp-challenge)
+// or a name including it (e.g. _myapp-challenge.website.com).
+func (h *DnsHost) HostName(domain, recordName string) string {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	relativeName := relativeRecordName(domain, recordName)
+
+	if h.FullyQualified {
+		name := domain
+		if relativeName != "" {
+			name = fmt.Sprintf("%s.%s", relativeName, domain)
+		}
+		if h.TrailingDot {
+			name = dns.Fqdn(name)
+		}
+		return name
+	}
+
+	if relativeName == "" {
+		return h.ApexName
+	}
+	return relativeName
+}
+
+// RecordValue returns the value to enter in the dashboard of the provider for a record of the type (TXT or CNAME).
+// TXT values are quoted, and split into strings of 255 characters, when the provider requires it.
+func (h *DnsHost) RecordValue(recordType, value string) string {
+	if !strings.EqualFold(recordType, "TXT") || !h.QuoteTxt {
+		return value
+	}
+
+	strs := splitTxtStrings(value)
+	for i, str := range strs {
+		strs[i] = quoteTxtString(str)
+	}
+	return strings.Join(strs, " ")
+}
+
+// splitTxtStrings splits a TXT content into character strings of 255 bytes at most.
+// The strings are cut on rune boundaries, so that a multibyte character is never split.
+func splitTxtStrings(value string) []string {
+	var strs []string
+	for len(value) > maxTxtStringLength {
+		cut := maxTxtStringLength
+		for cut > 0 && !utf8.RuneStart(value[cut]) {
+			cut--
+		}
+		strs = append(strs, value[:cut])
+		value = value[cut:]
+	}
+	return append(strs, value)
+}
+
+func quoteTxtString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return fmt.Sprintf(`"%s"`, strings.ReplaceAll(s, `"`, `\"`))
+}
+
+// relativeRecordName returns the name of a record relative to the domain, empty for the apex.
+func relativeRecordName(domain, recordName string) string {
+	recordName = strings.TrimSuffix(strings.TrimSpace(recordName), ".")
+	lowerName, lowerDomain := strings.ToLower(recordName), strings.ToLower(domain)
+	switch {
+	case recordName == rootDomain || lowerName == lowerDomain:
+		return ""
+	case strings.HasSuffix(lowerName, "."+lowerDomain):
+		return recordName[:len(recordName)-len(domain)-1]
+	default:
+		return recordName
+	}
+}
+
+// GenerateDnsHostInstruction adapts a TXT or CNAME record instruction to the dashboard of a DNS hosting provider:
+// the host name and the value are formatted as the provider expects them, and the action names the fields
+// of the dashboard and suggests a TTL. The DnsHostGeneric conventions are used when host is nil.
+// options customizes the action like the ActionOptions of the generator configs (template and language),
+// it can be nil.
+//
+// Example:
+//
+//	instruction, _ := domainverifier.GenerateTxtChallenge("myapp", false)
+//	host, _ := domainverifier.DetectDnsHost(dnsresolver.CloudflareDNS, "website.com")
+//	instruction, err := domainverifier.GenerateDnsHostInstruction(instruction, "website.com", host, nil)
+func GenerateDnsHostInstruction(instruction *DnsRecordInstruction, domain string, host *DnsHost,
+	options *config.ActionOptions) (*DnsRecordInstruction, error) {
+	if instruction == nil {
+		return nil, errors.New("instruction cannot be nil")
+	}
+	if !IsValidDomainName(domain) {
+		return nil, InvalidDomainError
+	}
+	if host == nil {
+		host = DnsHostGeneric
+	}
+	var actionOptions config.ActionOptions
+	if options != nil {
+		actionOptions = *options
+	}
+	if actionOptions.Domain == "" {
+		actionOptions.Domain = domain
+	}
+
+	recordType := "TXT"
+	if instruction.Method == MethodCnameRecord {
+		recordType = "CNAME"
+	}
+
+	adapted := &dnsHostInstruction{
+		host:       host,
+		domain:     domain,
+		recordType: recordType,
+		hostName:   host.HostName(domain, instruction.HostName),
+		record:     host.RecordValue(recordType, instruction.Record),
+		language:   actionOptions.Language,
+	}
+	action, err := executeActionTemplate(actionOptions, TemplateData{
+		Method:     instruction.Method,
+		Content:    adapted.record,
+		HostName:   adapted.hostName,
+		Record:     adapted.record,
+		RecordType: recordType,
+	}, adapted)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DnsRecordInstruction{
+		Method:   instruction.Method,
+		HostName: adapted.hostName,
+		Record:   adapted.record,
+		Action:   action,
+	}, nil
+}
+
+// dnsHostInstruction is a record instruction adapted to a DNS hosting provider, see GenerateDnsHostInstruction.
+type dnsHostInstruction struct {
+	host       *DnsHost
+	domain     string
+	recordType string
+	hostName   string
+	record     string
+	language   string // language of the placeholder of an empty host name
+}
+
+// Steps returns the single step of the instruction: the fields to fill in the dashboard of the provider.
+func (i *dnsHostInstruction) Steps() []Step {
+	displayedName := i.hostName
+	if displayedName == "" {
+		displayedName = Translate(MessageDnsHostEmptyName, i.language)
+	}
+
+	create := newStep(MessageDnsHostCreate, i.host.Name, i.recordType, i.domain)
+	create.Code = fmt.Sprintf("%s: %s\n%s: %s\nTTL: %s",
+		i.host.NameLabel, displayedName, i.host.ValueLabel, i.record, i.host.TTLHint)
+	return []Step{create}
+}
+
+// DetectDnsHost detects the DNS hosting provider of the domain from its NS records.
+// The parent domains are queried until NS records are found, so that a subdomain is detected as well.
+// DnsHostGeneric is returned when the provider is not known.
+// If dnsResolver is empty, dnsresolver.CloudflareDNS is used.
+func DetectDnsHost(dnsResolver, domain string) (*DnsHost, error) {
+	if strings.TrimSpace(dnsResolver) == "" {
+		dnsResolver = dnsresolver.CloudflareDNS
+	}
+	if !IsValidDomainName(domain) {
+		return nil, InvalidDomainError
+	}
+
+	name := strings.TrimSuffix(domain, ".")
+	for strings.Contains(name, ".") {
+		r, err := exchangeDNS(dnsResolver, dns.Fqdn(name), dns.TypeNS)
+		if err != nil {
+			return nil, err
+		}
+
+		var nameServers []string
+		for _, answer := range r.Answer {
+			if ns, ok := answer.(*dns.NS); ok {
+				nameServers = append(nameServers, strings.ToLower(ns.Ns))
+			}
+		}
+		if len(nameServers) > 0 {
+			return matchDnsHost(nameServers), nil
+		}
+
+		name = name[strings.Index(name, ".")+1:]
+	}
+	return DnsHostGeneric, nil
+}
+
+// matchDnsHost returns the provider whose patterns match one of the name servers.
+func matchDnsHost(nameServers []string) *DnsHost {
+	for _, host := range dnsHosts {
+		for _, pattern := range host.NameServerPatterns {
+			for _, nameServer := range nameServers {
+				if strings.Contains(nameServer, pattern) {
+					return host
+				}
+			}
+		}
+	}
+	return DnsHostGeneric
+}
@@ -0,0 +1,160 @@
+package domainverifier
+
+import (
+	"github.com/egbakou/domainverifier/config"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDnsHostHostName(t *testing.T) {
+	fullyQualified := &DnsHost{FullyQualified: true, TrailingDot: true}
+	tests := []struct {
+		name       string
+		host       *DnsHost
+		recordName string
+		want       string
+	}{
+		{"cloudflare apex", DnsHostCloudflare, "@", "@"},
+		{"cloudflare challenge label", DnsHostCloudflare, "_myapp-challenge.website.com", "_myapp-challenge"},
+		{"route 53 apex", DnsHostRoute53, "@", ""},
+		{"route 53 apex as domain", DnsHostRoute53, "Website.com.", ""},
+		{"google domains challenge label", DnsHostGoogleDomains, "_myapp-challenge", "_myapp-challenge"},
+		{"fully qualified apex", fullyQualified, "@", "website.com."},
+		{"fully qualified challenge label", fullyQualified, "_myapp-challenge", "_myapp-challenge.website.com."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.host.HostName("website.com", tt.recordName); got != tt.want {
+				t.Errorf("HostName() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDnsHostRecordValue(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := []struct {
+		name       string
+		host       *DnsHost
+		recordType string
+		value      string
+		want       string
+	}{
+		{"cloudflare txt", DnsHostCloudflare, "TXT", "myapp=1234", "myapp=1234"},
+		{"route 53 txt", DnsHostRoute53, "TXT", "myapp=1234", `"myapp=1234"`},
+		{"route 53 txt with quote", DnsHostRoute53, "TXT", `my"app=1234`, `"my\"app=1234"`},
+		{"route 53 long txt", DnsHostRoute53, "TXT", long, `"` + long[:255] + `" "` + long[255:] + `"`},
+		{"route 53 cname", DnsHostRoute53, "CNAME", "verify.myapp.com", "verify.myapp.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.host.RecordValue(tt.recordType, tt.value); got != tt.want {
+				t.Errorf("RecordValue() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateDnsHostInstruction(t *testing.T) {
+	txt := &DnsRecordInstruction{Method: MethodTxtRecord, HostName: "@", Record: "myapp=1234"}
+
+	got, err := GenerateDnsHostInstruction(txt, "website.com", DnsHostRoute53, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := "In Amazon Route 53, create a TXT record for website.com with the following fields:\n" +
+		"Record name: leave it empty\n" +
+		"Value: \"myapp=1234\"\n" +
+		"TTL: 300"
+	if got.Action != want {
+		t.Errorf("expected: %q, got: %q", want, got.Action)
+	}
+	if got.HostName != "" || got.Record != `"myapp=1234"` || got.Method != MethodTxtRecord {
+		t.Errorf("unexpected instruction: %+v", got)
+	}
+
+	cname := &DnsRecordInstruction{Method: MethodCnameRecord, HostName: "abcd", Record: "verify.myapp.com"}
+	got, err = GenerateDnsHostInstruction(cname, "website.com", nil, nil)
+	if err != nil || !strings.HasPrefix(got.Action, "In your DNS hosting provider, create a CNAME record") {
+		t.Errorf("expected the generic instruction, got: %+v, %v", got, err)
+	}
+
+	if _, err := GenerateDnsHostInstruction(txt, "not a domain", nil, nil); err != InvalidDomainError {
+		t.Errorf("expected: %v, got: %v", InvalidDomainError, err)
+	}
+
+	got, err = GenerateDnsHostInstruction(txt, "website.com", DnsHostRoute53, &config.ActionOptions{Language: "fr"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want = "Dans Amazon Route 53, créez un enregistrement TXT pour website.com avec les champs suivants :\n" +
+		"Record name: laissez-le vide\n" +
+		"Value: \"myapp=1234\"\n" +
+		"TTL: 300"
+	if got.Action != want {
+		t.Errorf("expected: %q, got: %q", want, got.Action)
+	}
+
+	got, err = GenerateDnsHostInstruction(txt, "website.com", DnsHostCloudflare,
+		&config.ActionOptions{ActionTemplate: "{{.RecordType}} {{.HostName}} {{.Record}} on {{.Domain}}"})
+	if err != nil || got.Action != "TXT @ myapp=1234 on website.com" {
+		t.Errorf("expected the action of the template, got: %+v, %v", got, err)
+	}
+}
+
+func TestSplitTxtStrings(t *testing.T) {
+	// 254 bytes followed by a 2-byte character: the first string stops before it
+	value := strings.Repeat("a", 254) + "é" + "b"
+	got := splitTxtStrings(value)
+	if len(got) != 2 || got[0] != strings.Repeat("a", 254) || got[1] != "éb" {
+		t.Errorf("expected the strings to be cut before é, got: %q", got)
+	}
+	for _, str := range splitTxtStrings(strings.Repeat("日本", 200)) {
+		if len(str) > maxTxtStringLength || !utf8.ValidString(str) {
+			t.Errorf("expected a valid string of at most %d bytes, got: %q", maxTxtStringLength, str)
+		}
+	}
+}
+
+func TestDetectDnsHost(t *testing.T) {
+	resolver := startTestDNSServer(t, map[string][]string{
+		"website.com.": {
+			"website.com. 60 IN NS amy.ns.cloudflare.com.",
+			"website.com. 60 IN NS bob.ns.cloudflare.com.",
+		},
+		"aws.com.":      {"aws.com. 60 IN NS ns-1234.awsdns-12.org."},
+		"selfhost.com.": {"selfhost.com. 60 IN NS ns1.selfhost.com."},
+	})
+
+	tests := []struct {
+		domain string
+		want   *DnsHost
+	}{
+		{"website.com", DnsHostCloudflare},
+		{"shop.website.com", DnsHostCloudflare},
+		{"aws.com", DnsHostRoute53},
+		{"selfhost.com", DnsHostGeneric},
+		{"unknown.com", DnsHostGeneric},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			got, err := DetectDnsHost(resolver, tt.domain)
+			if err != nil {
+				t.Fatalf("DetectDnsHost() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectDnsHost() got = %v, want %v", got.ID, tt.want.ID)
+			}
+		})
+	}
+}
+
+func TestDnsHostByID(t *testing.T) {
+	if host, err := DnsHostByID(" Route53 "); err != nil || host != DnsHostRoute53 {
+		t.Errorf("expected: %v, got: %v, %v", DnsHostRoute53.ID, host, err)
+	}
+	if _, err := DnsHostByID("unknown"); err != UnknownDnsHostError {
+		t.Errorf("expected: %v, got: %v", UnknownDnsHostError, err)
+	}
+}
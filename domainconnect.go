@@ -0,0 +1,374 @@
+package domainverifier
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/egbakou/domainverifier/dnsresolver"
+	"github.com/miekg/dns"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+const (
+	domainConnectLabel = "_domainconnect"
+	// The values of the records are Domain Connect variables, set in the apply URL for each domain
+	domainConnectHostVariable = "recordhost"
+	domainConnectCodeVariable = "code"
+)
+
+var (
+	// DomainConnectNotSupportedError indicates that the DNS provider of the domain does not support Domain Connect.
+	DomainConnectNotSupportedError = errors.New("the DNS provider of the domain does not support Domain Connect")
+
+	// InvalidPrivateKeyError indicates that the Domain Connect signing key is not an RSA private key.
+	InvalidPrivateKeyError = errors.New("invalid private key, expected a PEM encoded RSA private key")
+
+	// InvalidDomainConnectHostError indicates that the _domainconnect TXT record of the domain is not a public host name,
+	// e.g. an IP address, a host with a port or a URL with a path.
+	InvalidDomainConnectHostError = errors.New("the _domainconnect record of the domain is not a public host name")
+)
+
+// DomainConnectService describes the verification service of an application in Domain Connect terms.
+type DomainConnectService struct {
+	ProviderID         string // domain of the application, e.g. myapp.com
+	ProviderName       string
+	ServiceID          string // e.g. verification
+	ServiceName        string
+	Description        string
+	LogoURL            string
+	SyncPubKeyDomain   string // domain holding the public keys of the signed apply URLs, empty when not signed
+	SyncRedirectDomain string // domains allowed in redirect_uri, comma separated
+}
+
+// DomainConnectTemplate is a Domain Connect service template, to be submitted to the DNS providers.
+type DomainConnectTemplate struct {
+	ProviderID         string                `json:"providerId"`
+	ProviderName       string                `json:"providerName"`
+	ServiceID          string                `json:"serviceId"`
+	ServiceName        string                `json:"serviceName"`
+	Version            int                   `json:"version"`
+	LogoURL            string                `json:"logoUrl,omitempty"`
+	Description        string                `json:"description,omitempty"`
+	SyncBlock          bool                  `json:"syncBlock"`
+	SharedProviderName bool                  `json:"sharedProviderName"`
+	SyncPubKeyDomain   string                `json:"syncPubKeyDomain,omitempty"`
+	SyncRedirectDomain string                `json:"syncRedirectDomain,omitempty"`
+	Records            []DomainConnectRecord `json:"records"`
+}
+
+// DomainConnectRecord is a record of a Domain Connect template.
+type DomainConnectRecord struct {
+	Type                      string `json:"type"`
+	Host                      string `json:"host"`
+	Data                      string `json:"data,omitempty"`
+	PointsTo                  string `json:"pointsTo,omitempty"`
+	TTL                       uint32 `json:"ttl"`
+	TxtConflictMatchingMode   string `json:"txtConflictMatchingMode,omitempty"`
+	TxtConflictMatchingPrefix string `json:"txtConflictMatchingPrefix,omitempty"`
+}
+
+// DomainConnectSettings are the Domain Connect settings of the DNS provider of a domain.
+type DomainConnectSettings struct {
+	ProviderID          string `json:"providerId"`
+	ProviderName        string `json:"providerName"`
+	ProviderDisplayName string `json:"providerDisplayName"`
+	UrlSyncUX           string `json:"urlSyncUX"`
+	UrlAsyncUX          string `json:"urlAsyncUX"`
+	UrlAPI              string `json:"urlAPI"`
+	Width               int    `json:"width"`
+	Height              int    `json:"height"`
+}
+
+// DomainConnectOptions customizes a synchronous apply URL.
+type DomainConnectOptions struct {
+	RedirectURI string          // optional URL the user is redirected to, in service.SyncRedirectDomain
+	State       string          // optional value passed back to RedirectURI
+	PrivateKey  *rsa.PrivateKey // optional key signing the URL, see ParseDomainConnectKey
+	KeyHost     string          // host of the TXT record of the public key in service.SyncPubKeyDomain (e.g. _dck1)
+}
+
+// GenerateDomainConnectTemplate generates the Domain Connect template of the service from a TXT or CNAME instruction,
+// as returned by GenerateTxtRecord or GenerateCnameRecordFromConfig. The host name of the record and the
+// verification code are variables of the template, set for each domain by DomainConnectApplyURL.
+// A TXT record replaces the existing records starting with the same attribute.
+//
+// Example:
+//
+//	instruction, _ := domainverifier.GenerateTxtRecord("myapp")
+//	template, err := domainverifier.GenerateDomainConnectTemplate(service, instruction)
+//	document, err := json.MarshalIndent(template, "", "  ")
+func GenerateDomainConnectTemplate(service *DomainConnectService, instruction *DnsRecordInstruction) (*DomainConnectTemplate, error) {
+	if err := validateDomainConnectService(service); err != nil {
+		return nil, err
+	}
+	if instruction == nil {
+		return nil, errors.New("instruction cannot be nil")
+	}
+
+	record := DomainConnectRecord{
+		Host: fmt.Sprintf("%%%s%%", domainConnectHostVariable),
+		TTL:  DefaultTTL,
+	}
+	if instruction.Method == MethodCnameRecord {
+		record.Type = "CNAME"
+		record.PointsTo = instruction.Record
+	} else {
+		attribute, _, found := strings.Cut(instruction.Record, "=")
+		if !found {
+			return nil, fmt.Errorf("TXT record %q is not an attribute=code pair", instruction.Record)
+		}
+		record.Type = "TXT"
+		record.Data = fmt.Sprintf("%s=%%%s%%", attribute, domainConnectCodeVariable)
+		record.TxtConflictMatchingMode = "Prefix"
+		record.TxtConflictMatchingPrefix = attribute + "="
+	}
+
+	return &DomainConnectTemplate{
+		ProviderID:         service.ProviderID,
+		ProviderName:       service.ProviderName,
+		ServiceID:          service.ServiceID,
+		ServiceName:        service.ServiceName,
+		Version:            1,
+		LogoURL:            service.LogoURL,
+		Description:        service.Description,
+		SyncPubKeyDomain:   service.SyncPubKeyDomain,
+		SyncRedirectDomain: service.SyncRedirectDomain,
+		Records:            []DomainConnectRecord{record},
+	}, nil
+}
+
+// DomainConnectApplyURL builds the synchronous apply URL of the template of the service for the domain,
+// setting the variables of the template from the instruction it was generated from.
+// The user opens the URL to apply the record with one click in the dashboard of the DNS provider.
+// When options.PrivateKey is set, the query string is signed with RSA-SHA256 (sig and key parameters).
+//
+// Example:
+//
+//	settings, _ := domainverifier.DiscoverDomainConnect(dnsresolver.CloudflareDNS, "website.com")
+//	applyURL, err := domainverifier.DomainConnectApplyURL(settings, service, "website.com", instruction, nil)
+func DomainConnectApplyURL(settings *DomainConnectSettings, service *DomainConnectService, domain string,
+	instruction *DnsRecordInstruction, options *DomainConnectOptions) (string, error) {
+	if settings == nil || strings.TrimSpace(settings.UrlSyncUX) == "" {
+		return "", DomainConnectNotSupportedError
+	}
+	if err := validateDomainConnectService(service); err != nil {
+		return "", err
+	}
+	if instruction == nil {
+		return "", errors.New("instruction cannot be nil")
+	}
+	if !IsValidDomainName(domain) {
+		return "", InvalidDomainError
+	}
+	if options == nil {
+		options = &DomainConnectOptions{}
+	}
+
+	recordHost := relativeRecordName(domain, instruction.HostName)
+	if recordHost == "" {
+		recordHost = rootDomain
+	}
+
+	query := url.Values{}
+	query.Set("domain", domain)
+	query.Set(domainConnectHostVariable, recordHost)
+	if instruction.Method != MethodCnameRecord {
+		_, code, _ := strings.Cut(instruction.Record, "=")
+		query.Set(domainConnectCodeVariable, code)
+	}
+	if options.RedirectURI != "" {
+		query.Set("redirect_uri", options.RedirectURI)
+	}
+	if options.State != "" {
+		query.Set("state", options.State)
+	}
+
+	queryString := query.Encode()
+	if options.PrivateKey != nil {
+		if strings.TrimSpace(options.KeyHost) == "" || strings.TrimSpace(service.SyncPubKeyDomain) == "" {
+			return "", errors.New("signing requires a key host and the SyncPubKeyDomain of the service")
+		}
+		signature, err := signDomainConnectQuery(options.PrivateKey, queryString)
+		if err != nil {
+			return "", err
+		}
+		queryString = fmt.Sprintf("%s&sig=%s&key=%s", queryString, url.QueryEscape(signature), url.QueryEscape(options.KeyHost))
+	}
+
+	return fmt.Sprintf("%s/v2/domainTemplates/providers/%s/services/%s/apply?%s",
+		strings.TrimSuffix(settings.UrlSyncUX, "/"), url.PathEscape(service.ProviderID),
+		url.PathEscape(service.ServiceID), queryString), nil
+}
+
+// signDomainConnectQuery returns the base64 RSA-SHA256 signature of the query string.
+func signDomainConnectQuery(key *rsa.PrivateKey, queryString string) (string, error) {
+	digest := sha256.Sum256([]byte(queryString))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// ParseDomainConnectKey parses the PEM encoded RSA private key (PKCS #1 or PKCS #8) signing the apply URLs.
+func ParseDomainConnectKey(pemData []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, InvalidPrivateKeyError
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, InvalidPrivateKeyError
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, InvalidPrivateKeyError
+	}
+	return rsaKey, nil
+}
+
+// LookupDomainConnect returns the Domain Connect API host of the DNS provider of the domain,
+// published in the TXT record _domainconnect of the domain.
+// DomainConnectNotSupportedError is returned when the record does not exist, and InvalidDomainConnectHostError
+// when it is not a public host name (an IP address, a host with a port or a URL with a path).
+// If dnsResolver is empty, dnsresolver.CloudflareDNS is used.
+func LookupDomainConnect(dnsResolver, domain string) (string, error) {
+	return lookupDomainConnect(context.Background(), dnsResolver, domain)
+}
+
+func lookupDomainConnect(ctx context.Context, dnsResolver, domain string) (string, error) {
+	if strings.TrimSpace(dnsResolver) == "" {
+		dnsResolver = dnsresolver.CloudflareDNS
+	}
+	if !IsValidDomainName(domain) {
+		return "", InvalidDomainError
+	}
+
+	r, err := exchangeDNSContext(ctx, dnsResolver, dnsRecordName(domain, domainConnectLabel), dns.TypeTXT)
+	if err != nil {
+		return "", err
+	}
+	for _, answer := range r.Answer {
+		if txt, ok := answer.(*dns.TXT); ok {
+			if host := strings.TrimSpace(strings.Join(txt.Txt, "")); host != "" {
+				if !isPublicHostName(host) {
+					return "", InvalidDomainConnectHostError
+				}
+				return host, nil
+			}
+		}
+	}
+	return "", DomainConnectNotSupportedError
+}
+
+// isPublicHostName reports whether host is a host name with at least two labels and a non-numeric
+// top-level label, so that it is neither an IP address nor a single-label name such as localhost.
+// Ports, paths and other URL parts are rejected by IsValidDomainName.
+func isPublicHostName(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if !IsValidDomainName(host) || net.ParseIP(host) != nil {
+		return false
+	}
+	dot := strings.LastIndex(host, ".")
+	if dot < 0 {
+		return false
+	}
+	topLevel := host[dot+1:]
+	return strings.IndexFunc(topLevel, func(r rune) bool { return !unicode.IsDigit(r) }) >= 0
+}
+
+// DiscoverDomainConnect looks up the Domain Connect API host of the DNS provider of the domain
+// and returns the Domain Connect settings of the domain, fetched from https://{host}/v2/{domain}/settings.
+// DomainConnectNotSupportedError is returned when the DNS provider does not support Domain Connect.
+//
+// The API host is read from the DNS zone of the domain, which is controlled by the owner of the domain,
+// so the request is sent to a host chosen by a possibly untrusted user. IP addresses, ports and paths are
+// rejected and only HTTPS is used, but a host name can still resolve to a private address: when the
+// domains are entered by untrusted users, use DiscoverDomainConnectContext with a client whose dialer
+// refuses the private and loopback addresses of your network.
+func DiscoverDomainConnect(dnsResolver, domain string) (*DomainConnectSettings, error) {
+	return DiscoverDomainConnectContext(context.Background(), dnsResolver, domain, nil)
+}
+
+// DiscoverDomainConnectContext is DiscoverDomainConnect with a context canceling the DNS query and the HTTP request,
+// and the client of the HTTP request. A client with DefaultHttpTimeout is used when client is nil.
+// Redirects to other schemes than HTTPS are refused, whatever the client.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	settings, err := domainverifier.DiscoverDomainConnectContext(ctx, dnsresolver.CloudflareDNS, "website.com", nil)
+func DiscoverDomainConnectContext(ctx context.Context, dnsResolver, domain string, client *http.Client) (*DomainConnectSettings, error) {
+	apiHost, err := lookupDomainConnect(ctx, dnsResolver, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := doHttpRequest(ctx, httpsOnlyClient(client), http.MethodGet, fmt.Sprintf("%s%s/v2/%s/settings", httpsPrefix, apiHost, domain))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, DomainConnectNotSupportedError
+	}
+
+	settings := &DomainConnectSettings{}
+	if err := json.NewDecoder(resp.Body).Decode(settings); err != nil {
+		return nil, err
+	}
+	if settings.UrlSyncUX == "" {
+		return nil, DomainConnectNotSupportedError
+	}
+	return settings, nil
+}
+
+// httpsOnlyClient returns a copy of the client, or of defaultHttpClient when it is nil,
+// refusing the redirects to other schemes than HTTPS.
+func httpsOnlyClient(client *http.Client) *http.Client {
+	if client == nil {
+		client = defaultHttpClient
+	}
+	c := *client
+	checkRedirect := client.CheckRedirect
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if req.URL.Scheme != "https" {
+			return fmt.Errorf("redirect to %s refused, only HTTPS is allowed", req.URL.Redacted())
+		}
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &c
+}
+
+func validateDomainConnectService(service *DomainConnectService) error {
+	if service == nil {
+		return errors.New("Domain Connect service cannot be nil")
+	}
+	if strings.TrimSpace(service.ProviderID) == "" || strings.TrimSpace(service.ServiceID) == "" {
+		return errors.New("Domain Connect provider ID and service ID cannot be empty")
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package domainverifier
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testDomainConnectService = &DomainConnectService{
+	ProviderID:   "myapp.com",
+	ProviderName: "MyApp",
+	ServiceID:    "verification",
+	ServiceName:  "MyApp domain verification",
+}
+
+func TestGenerateDomainConnectTemplate(t *testing.T) {
+	tests := []struct {
+		name        string
+		service     *DomainConnectService
+		instruction *DnsRecordInstruction
+		want        DomainConnectRecord
+		wantErr     bool
+	}{
+		{
+			name:        "txt record",
+			service:     testDomainConnectService,
+			instruction: &DnsRecordInstruction{Method: MethodTxtRecord, HostName: "@", Record: "myapp-site-verification=1234"},
+			want: DomainConnectRecord{Type: "TXT", Host: "%recordhost%", Data: "myapp-site-verification=%code%", TTL: DefaultTTL,
+				TxtConflictMatchingMode: "Prefix", TxtConflictMatchingPrefix: "myapp-site-verification="},
+		},
+		{
+			name:        "cname record",
+			service:     testDomainConnectService,
+			instruction: &DnsRecordInstruction{Method: MethodCnameRecord, HostName: "abcd", Record: "verify.myapp.com"},
+			want:        DomainConnectRecord{Type: "CNAME", Host: "%recordhost%", PointsTo: "verify.myapp.com", TTL: DefaultTTL},
+		},
+		{
+			name:        "txt record without attribute",
+			service:     testDomainConnectService,
+			instruction: &DnsRecordInstruction{Method: MethodTxtRecord, HostName: "@", Record: "1234"},
+			wantErr:     true,
+		},
+		{
+			name:        "service without ID",
+			service:     &DomainConnectService{ProviderID: "myapp.com"},
+			instruction: &DnsRecordInstruction{Method: MethodTxtRecord, HostName: "@", Record: "myapp=1234"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateDomainConnectTemplate(tt.service, tt.instruction)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got.Records) != 1 || !reflect.DeepEqual(got.Records[0], tt.want) {
+				t.Errorf("expected: %+v, got: %+v", tt.want, got.Records)
+			}
+			if got.ProviderID != tt.service.ProviderID || got.ServiceID != tt.service.ServiceID || got.Version != 1 {
+				t.Errorf("unexpected template: %+v", got)
+			}
+		})
+	}
+}
+
+func TestDomainConnectTemplateJson(t *testing.T) {
+	template, err := GenerateDomainConnectTemplate(testDomainConnectService,
+		&DnsRecordInstruction{Method: MethodTxtRecord, HostName: "@", Record: "myapp=1234"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	document, err := json.Marshal(template)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, field := range []string{`"providerId":"myapp.com"`, `"serviceId":"verification"`, `"syncBlock":false`,
+		`"data":"myapp=%code%"`, `"host":"%recordhost%"`} {
+		if !strings.Contains(string(document), field) {
+			t.Errorf("expected %s in %s", field, document)
+		}
+	}
+	if strings.Contains(string(document), "pointsTo") || strings.Contains(string(document), "syncPubKeyDomain") {
+		t.Errorf("unexpected empty fields in %s", document)
+	}
+}
+
+func TestDomainConnectApplyURL(t *testing.T) {
+	settings := &DomainConnectSettings{UrlSyncUX: "https://dcc.provider.com/"}
+	txt := &DnsRecordInstruction{Method: MethodTxtRecord, HostName: "_myapp-challenge.website.com", Record: "myapp=1234"}
+	cname := &DnsRecordInstruction{Method: MethodCnameRecord, HostName: "abcd", Record: "verify.myapp.com"}
+	prefix := "https://dcc.provider.com/v2/domainTemplates/providers/myapp.com/services/verification/apply?"
+
+	tests := []struct {
+		name        string
+		settings    *DomainConnectSettings
+		instruction *DnsRecordInstruction
+		options     *DomainConnectOptions
+		want        string
+		wantErr     error
+	}{
+		{
+			name:        "txt record",
+			settings:    settings,
+			instruction: txt,
+			want:        prefix + "code=1234&domain=website.com&recordhost=_myapp-challenge",
+		},
+		{
+			name:        "cname record with redirect",
+			settings:    settings,
+			instruction: cname,
+			options:     &DomainConnectOptions{RedirectURI: "https://myapp.com/domains?id=1", State: "xyz"},
+			want: prefix + "domain=website.com&recordhost=abcd" +
+				"&redirect_uri=https%3A%2F%2Fmyapp.com%2Fdomains%3Fid%3D1&state=xyz",
+		},
+		{
+			name:        "apex",
+			settings:    settings,
+			instruction: &DnsRecordInstruction{Method: MethodTxtRecord, HostName: "", Record: "myapp=1234"},
+			want:        prefix + "code=1234&domain=website.com&recordhost=%40",
+		},
+		{
+			name:        "synchronous flow not supported",
+			settings:    &DomainConnectSettings{UrlAsyncUX: "https://dcc.provider.com"},
+			instruction: txt,
+			wantErr:     DomainConnectNotSupportedError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DomainConnectApplyURL(tt.settings, testDomainConnectService, "website.com", tt.instruction, tt.options)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDomainConnectApplyURLSigned(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	settings := &DomainConnectSettings{UrlSyncUX: "https://dcc.provider.com"}
+	instruction := &DnsRecordInstruction{Method: MethodTxtRecord, HostName: "@", Record: "myapp=1234"}
+	options := &DomainConnectOptions{PrivateKey: key, KeyHost: "_dck1"}
+
+	if _, err := DomainConnectApplyURL(settings, testDomainConnectService, "website.com", instruction, options); err == nil {
+		t.Errorf("expected an error without SyncPubKeyDomain")
+	}
+
+	service := *testDomainConnectService
+	service.SyncPubKeyDomain = "keys.myapp.com"
+	applyURL, err := DomainConnectApplyURL(settings, &service, "website.com", instruction, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	queryString := applyURL[strings.Index(applyURL, "?")+1:]
+	signed, params, found := strings.Cut(queryString, "&sig=")
+	if !found {
+		t.Fatalf("expected a signature in %s", applyURL)
+	}
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if values.Get("key") != "_dck1" {
+		t.Errorf("expected: %v, got: %v", "_dck1", values.Get("key"))
+	}
+	sig, _, _ := strings.Cut(params, "&key=")
+	sig, _ = url.QueryUnescape(sig)
+	signature, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	digest := sha256.Sum256([]byte(signed))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("invalid signature: %v", err)
+	}
+}
+
+func TestParseDomainConnectKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+
+	tests := []struct {
+		name    string
+		pemData []byte
+		wantErr bool
+	}{
+		{"pkcs1", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), false},
+		{"pkcs8", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), false},
+		{"not pem", []byte("not a key"), true},
+		{"not a key", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("1234")}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDomainConnectKey(tt.pemData)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !got.Equal(key) {
+				t.Errorf("parsed key differs from the original key")
+			}
+		})
+	}
+}
+
+func TestDiscoverDomainConnect(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/website.com/settings":
+			_, _ = w.Write([]byte(`{"providerId":"provider.com","providerName":"Provider","urlSyncUX":"https://dcc.provider.com"}`))
+		case "/v2/redirected.com/settings":
+			http.Redirect(w, r, "http://api.provider.com/v2/website.com/settings", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// The client sends the requests of any host to the server, whose certificate is issued for example.com
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.ServerName = "example.com"
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	client := &http.Client{Transport: transport}
+
+	apiHost := "api.provider.com"
+	resolver := startTestDNSServer(t, map[string][]string{
+		"_domainconnect.website.com.":    {`_domainconnect.website.com. 60 IN TXT "` + apiHost + `"`},
+		"_domainconnect.other.com.":      {`_domainconnect.other.com. 60 IN TXT "` + apiHost + `"`},
+		"_domainconnect.redirected.com.": {`_domainconnect.redirected.com. 60 IN TXT "` + apiHost + `"`},
+	})
+
+	host, err := LookupDomainConnect(resolver, "website.com")
+	if err != nil || host != apiHost {
+		t.Errorf("expected: %v, got: %v (%v)", apiHost, host, err)
+	}
+
+	ctx := context.Background()
+	settings, err := DiscoverDomainConnectContext(ctx, resolver, "website.com", client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if settings.ProviderID != "provider.com" || settings.UrlSyncUX != "https://dcc.provider.com" {
+		t.Errorf("unexpected settings: %+v", settings)
+	}
+
+	if _, err := DiscoverDomainConnectContext(ctx, resolver, "other.com", client); !errors.Is(err, DomainConnectNotSupportedError) {
+		t.Errorf("expected: %v, got: %v", DomainConnectNotSupportedError, err)
+	}
+	if _, err := DiscoverDomainConnectContext(ctx, resolver, "unknown.com", client); !errors.Is(err, DomainConnectNotSupportedError) {
+		t.Errorf("expected: %v, got: %v", DomainConnectNotSupportedError, err)
+	}
+	if _, err := DiscoverDomainConnectContext(ctx, resolver, "redirected.com", client); err == nil || !strings.Contains(err.Error(), "only HTTPS") {
+		t.Errorf("expected the redirect to HTTP to be refused, got: %v", err)
+	}
+}
+
+func TestLookupDomainConnectInvalidHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		wantErr error
+	}{
+		{"host name", "api.provider.com", nil},
+		{"fully qualified host name", "api.provider.com.", nil},
+		{"ipv4 address", "127.0.0.1", InvalidDomainConnectHostError},
+		{"ipv6 address", "::1", InvalidDomainConnectHostError},
+		{"bracketed ipv6 address", "[::1]", InvalidDomainConnectHostError},
+		{"port", "api.provider.com:8443", InvalidDomainConnectHostError},
+		{"path", "api.provider.com/domainconnect", InvalidDomainConnectHostError},
+		{"url", "https://api.provider.com", InvalidDomainConnectHostError},
+		{"single label", "localhost", InvalidDomainConnectHostError},
+		{"numeric top-level label", "169.254.169.254.", InvalidDomainConnectHostError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := startTestDNSServer(t, map[string][]string{
+				"_domainconnect.website.com.": {`_domainconnect.website.com. 60 IN TXT "` + tt.host + `"`},
+			})
+			_, err := LookupDomainConnect(resolver, "website.com")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
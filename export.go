@@ -0,0 +1,178 @@
+package domainverifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"strings"
+)
+
+// DefaultTTL is the TTL of the exported records when none is specified.
+const DefaultTTL = 3600
+
+// InvalidDnsRecordError indicates that the name of a record is not the first labels of its fully qualified name.
+var InvalidDnsRecordError = errors.New("invalid DNS record, its name does not match its fully qualified name")
+
+// DnsRecord is the generic representation of the record of a TXT or CNAME instruction,
+// exported as a zone file line, a Terraform resource or JSON.
+type DnsRecord struct {
+	Type  string `json:"type"`  // TXT or CNAME
+	Name  string `json:"name"`  // name relative to the domain, @ for the apex
+	Fqdn  string `json:"fqdn"`  // fully qualified name, with a trailing dot
+	Value string `json:"value"` // TXT content or CNAME target, without quotes or trailing dot
+	TTL   uint32 `json:"ttl"`
+}
+
+// NewDnsRecord returns the record of a TXT or CNAME instruction for the domain.
+// The instruction is expected as generated by GenerateTxtRecordFromConfig, GenerateCnameRecordFromConfig
+// and the like, not adapted to a provider by GenerateDnsHostInstruction. DefaultTTL is used when ttl is 0.
+//
+// Example:
+//
+//	instruction, _ := domainverifier.GenerateTxtChallenge("myapp", false)
+//	record, err := domainverifier.NewDnsRecord(instruction, "website.com", 300)
+//	fmt.Println(record.ZoneFile())
+//	// _myapp-challenge.website.com. 300 IN TXT "myapp-site-verification=2OjBkQ6Ub0FN0L5Wn5x8bHzoCKd"
+func NewDnsRecord(instruction *DnsRecordInstruction, domain string, ttl uint32) (*DnsRecord, error) {
+	if instruction == nil {
+		return nil, errors.New("instruction cannot be nil")
+	}
+	if !IsValidDomainName(domain) {
+		return nil, InvalidDomainError
+	}
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+
+	domain = strings.TrimSuffix(domain, ".")
+	record := &DnsRecord{Type: "TXT", Name: rootDomain, Fqdn: dns.Fqdn(domain), Value: instruction.Record, TTL: ttl}
+	if instruction.Method == MethodCnameRecord {
+		record.Type = "CNAME"
+		record.Value = strings.TrimSuffix(instruction.Record, ".")
+	}
+	if name := relativeRecordName(domain, instruction.HostName); name != "" {
+		record.Name = name
+		record.Fqdn = dns.Fqdn(fmt.Sprintf("%s.%s", name, domain))
+	}
+	return record, nil
+}
+
+// ZoneFile returns the record as an RFC 1035 zone file line. TXT contents are quoted and split
+// into strings of 255 characters, CNAME targets are fully qualified.
+func (r *DnsRecord) ZoneFile() string {
+	return fmt.Sprintf("%s %d IN %s %s", r.Fqdn, r.TTL, r.Type, r.zoneFileValue())
+}
+
+func (r *DnsRecord) zoneFileValue() string {
+	if r.Type == "CNAME" {
+		return dns.Fqdn(r.Value)
+	}
+
+	strs := splitTxtStrings(r.Value)
+	for i, str := range strs {
+		strs[i] = quoteTxtString(str)
+	}
+	return strings.Join(strs, " ")
+}
+
+// Terraform returns the record as a Terraform resource for the DNS hosting provider:
+// cloudflare_record for DnsHostCloudflare, aws_route53_record for DnsHostRoute53 and,
+// for any other provider, a resource of the hashicorp/dns provider (RFC 2136 dynamic updates).
+// The zone ID of the Cloudflare and Route 53 resources is read from var.zone_id.
+// InvalidDnsRecordError is returned when Name does not match Fqdn, e.g. in a record built by hand.
+func (r *DnsRecord) Terraform(host *DnsHost) (string, error) {
+	resourceName := strings.ToLower(r.Type) + "_verification"
+	fqdn := strings.TrimSuffix(r.Fqdn, ".")
+	zone, err := r.zone()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	switch host {
+	case DnsHostCloudflare:
+		sb.WriteString(fmt.Sprintf("resource \"cloudflare_record\" %q {\n", resourceName))
+		sb.WriteString("  zone_id = var.zone_id\n")
+		sb.WriteString(fmt.Sprintf("  name    = %s\n", hclString(r.Name)))
+		sb.WriteString(fmt.Sprintf("  type    = %s\n", hclString(r.Type)))
+		sb.WriteString(fmt.Sprintf("  content = %s\n", hclString(r.Value)))
+		sb.WriteString(fmt.Sprintf("  ttl     = %d\n", r.TTL))
+	case DnsHostRoute53:
+		value := r.Value
+		if r.Type == "TXT" {
+			// Route 53 splits the TXT strings on "" in the records of the resource
+			value = strings.Join(splitTxtStrings(r.Value), `""`)
+		}
+		sb.WriteString(fmt.Sprintf("resource \"aws_route53_record\" %q {\n", resourceName))
+		sb.WriteString("  zone_id = var.zone_id\n")
+		sb.WriteString(fmt.Sprintf("  name    = %s\n", hclString(fqdn)))
+		sb.WriteString(fmt.Sprintf("  type    = %s\n", hclString(r.Type)))
+		sb.WriteString(fmt.Sprintf("  ttl     = %d\n", r.TTL))
+		sb.WriteString(fmt.Sprintf("  records = [%s]\n", hclString(value)))
+	default:
+		name := ""
+		if r.Name != rootDomain {
+			name = r.Name
+		}
+		if r.Type == "CNAME" {
+			sb.WriteString(fmt.Sprintf("resource \"dns_cname_record\" %q {\n", resourceName))
+			sb.WriteString(fmt.Sprintf("  zone  = %s\n", hclString(dns.Fqdn(zone))))
+			sb.WriteString(fmt.Sprintf("  name  = %s\n", hclString(name)))
+			sb.WriteString(fmt.Sprintf("  cname = %s\n", hclString(dns.Fqdn(r.Value))))
+			sb.WriteString(fmt.Sprintf("  ttl   = %d\n", r.TTL))
+		} else {
+			sb.WriteString(fmt.Sprintf("resource \"dns_txt_record_set\" %q {\n", resourceName))
+			sb.WriteString(fmt.Sprintf("  zone = %s\n", hclString(dns.Fqdn(zone))))
+			if name != "" {
+				sb.WriteString(fmt.Sprintf("  name = %s\n", hclString(name)))
+			}
+			sb.WriteString(fmt.Sprintf("  txt  = [%s]\n", hclString(r.Value)))
+			sb.WriteString(fmt.Sprintf("  ttl  = %d\n", r.TTL))
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String(), nil
+}
+
+// zone returns the zone of the record: its fully qualified name without its name and the trailing dot.
+func (r *DnsRecord) zone() (string, error) {
+	fqdn := strings.TrimSuffix(r.Fqdn, ".")
+	if r.Name == rootDomain {
+		return fqdn, nil
+	}
+
+	prefix := r.Name + "."
+	if r.Name == "" || len(fqdn) <= len(prefix) || !strings.EqualFold(fqdn[:len(prefix)], prefix) {
+		return "", InvalidDnsRecordError
+	}
+	return fqdn[len(prefix):], nil
+}
+
+// hclString returns s as a quoted HCL string, escaping the quotes, the backslashes,
+// the control characters and the template sequences ${ and %{.
+func hclString(s string) string {
+	var sb strings.Builder
+	sb.WriteString(`"`)
+	for i, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r < 0x20:
+			sb.WriteString(fmt.Sprintf(`\u%04x`, r))
+		case (r == '$' || r == '%') && strings.HasPrefix(s[i+1:], "{"):
+			sb.WriteRune(r)
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteString(`"`)
+	return sb.String()
+}
@@ -0,0 +1,214 @@
+package domainverifier
+
+import (
+	"encoding/json"
+	"github.com/miekg/dns"
+	"strings"
+	"testing"
+)
+
+func TestNewDnsRecord(t *testing.T) {
+	type args struct {
+		instruction *DnsRecordInstruction
+		domain      string
+		ttl         uint32
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    DnsRecord
+		wantErr bool
+	}{
+		{
+			name: "apex txt with default ttl",
+			args: args{&DnsRecordInstruction{Method: MethodTxtRecord, HostName: "@", Record: "myapp=1234"}, "website.com", 0},
+			want: DnsRecord{Type: "TXT", Name: "@", Fqdn: "website.com.", Value: "myapp=1234", TTL: DefaultTTL},
+		},
+		{
+			name: "challenge label including the domain",
+			args: args{&DnsRecordInstruction{Method: MethodTxtRecord, HostName: "_myapp-challenge.website.com", Record: "myapp=1234"},
+				"website.com", 300},
+			want: DnsRecord{Type: "TXT", Name: "_myapp-challenge", Fqdn: "_myapp-challenge.website.com.", Value: "myapp=1234", TTL: 300},
+		},
+		{
+			name: "cname",
+			args: args{&DnsRecordInstruction{Method: MethodCnameRecord, HostName: "abcd", Record: "verify.myapp.com."}, "website.com", 60},
+			want: DnsRecord{Type: "CNAME", Name: "abcd", Fqdn: "abcd.website.com.", Value: "verify.myapp.com", TTL: 60},
+		},
+		{
+			name:    "invalid domain",
+			args:    args{&DnsRecordInstruction{Method: MethodTxtRecord, HostName: "@"}, "not a domain", 0},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewDnsRecord(tt.args.instruction, tt.args.domain, tt.args.ttl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewDnsRecord() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("expected: %+v, got: %+v", tt.want, *got)
+			}
+		})
+	}
+}
+
+func TestDnsRecordZoneFile(t *testing.T) {
+	long := strings.Repeat("a", 260)
+	tests := []struct {
+		name   string
+		record DnsRecord
+		want   string
+	}{
+		{
+			name:   "txt",
+			record: DnsRecord{Type: "TXT", Fqdn: "website.com.", Value: `myapp="1234"`, TTL: 300},
+			want:   `website.com. 300 IN TXT "myapp=\"1234\""`,
+		},
+		{
+			name:   "long txt",
+			record: DnsRecord{Type: "TXT", Fqdn: "website.com.", Value: long, TTL: 300},
+			want:   `website.com. 300 IN TXT "` + long[:255] + `" "` + long[255:] + `"`,
+		},
+		{
+			name:   "cname",
+			record: DnsRecord{Type: "CNAME", Fqdn: "abcd.website.com.", Value: "verify.myapp.com", TTL: 60},
+			want:   "abcd.website.com. 60 IN CNAME verify.myapp.com.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.record.ZoneFile()
+			if got != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+			// the line must be parsed back by a zone file parser
+			rr, err := dns.NewRR(got)
+			if err != nil {
+				t.Fatalf("cannot parse the zone file line: %v", err)
+			}
+			if txt, ok := rr.(*dns.TXT); ok && len(txt.Txt) != (len(tt.record.Value)+254)/255 {
+				t.Errorf("expected the content split into strings of 255 characters, got: %v", txt.Txt)
+			}
+		})
+	}
+}
+
+func TestDnsRecordTerraform(t *testing.T) {
+	txt := &DnsRecord{Type: "TXT", Name: "_myapp-challenge", Fqdn: "_myapp-challenge.website.com.", Value: "myapp=${1234}", TTL: 300}
+	apex := &DnsRecord{Type: "TXT", Name: "@", Fqdn: "website.com.", Value: "myapp=1234", TTL: 300}
+	cname := &DnsRecord{Type: "CNAME", Name: "abcd", Fqdn: "abcd.website.com.", Value: "verify.myapp.com", TTL: 60}
+
+	tests := []struct {
+		name   string
+		record *DnsRecord
+		host   *DnsHost
+		want   string
+	}{
+		{
+			name:   "cloudflare",
+			record: txt,
+			host:   DnsHostCloudflare,
+			want: `resource "cloudflare_record" "txt_verification" {
+  zone_id = var.zone_id
+  name    = "_myapp-challenge"
+  type    = "TXT"
+  content = "myapp=$${1234}"
+  ttl     = 300
+}
+`,
+		},
+		{
+			name:   "route 53",
+			record: cname,
+			host:   DnsHostRoute53,
+			want: `resource "aws_route53_record" "cname_verification" {
+  zone_id = var.zone_id
+  name    = "abcd.website.com"
+  type    = "CNAME"
+  ttl     = 60
+  records = ["verify.myapp.com"]
+}
+`,
+		},
+		{
+			name:   "dns provider txt at the apex",
+			record: apex,
+			host:   nil,
+			want: `resource "dns_txt_record_set" "txt_verification" {
+  zone = "website.com."
+  txt  = ["myapp=1234"]
+  ttl  = 300
+}
+`,
+		},
+		{
+			name:   "dns provider cname",
+			record: cname,
+			host:   DnsHostGoDaddy,
+			want: `resource "dns_cname_record" "cname_verification" {
+  zone  = "website.com."
+  name  = "abcd"
+  cname = "verify.myapp.com."
+  ttl   = 60
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.record.Terraform(tt.host)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected:\n%v\ngot:\n%v", tt.want, got)
+			}
+		})
+	}
+
+	mismatches := []*DnsRecord{
+		{Type: "TXT", Name: "_myapp-challenge", Fqdn: "website.com.", Value: "myapp=1234"},
+		{Type: "TXT", Name: "other", Fqdn: "_myapp-challenge.website.com.", Value: "myapp=1234"},
+		{Type: "CNAME", Name: "", Fqdn: "abcd.website.com.", Value: "verify.myapp.com"},
+	}
+	for _, record := range mismatches {
+		for _, host := range []*DnsHost{nil, DnsHostCloudflare, DnsHostRoute53} {
+			if _, err := record.Terraform(host); err != InvalidDnsRecordError {
+				t.Errorf("expected: %v, got: %v", InvalidDnsRecordError, err)
+			}
+		}
+	}
+}
+
+func TestDnsRecordJson(t *testing.T) {
+	record := &DnsRecord{Type: "TXT", Name: "@", Fqdn: "website.com.", Value: "myapp=1234", TTL: 300}
+	got, err := json.Marshal(record)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := `{"type":"TXT","name":"@","fqdn":"website.com.","value":"myapp=1234","ttl":300}`
+	if string(got) != want {
+		t.Errorf("expected: %v, got: %v", want, string(got))
+	}
+}
+
+func TestHclString(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain", `"plain"`},
+		{`quote " and \`, `"quote \" and \\"`},
+		{"${var} %{if} $5", `"$${var} %%{if} $5"`},
+		{"line\nbreak", `"line\nbreak"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := hclString(tt.value); got != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
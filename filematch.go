@@ -0,0 +1,371 @@
+package domainverifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// xmlAttributePrefix prefixes the attribute names in a decoded XML document.
+	xmlAttributePrefix = "@"
+	// xmlTextKey holds the text of an element having attributes in a decoded XML document.
+	xmlTextKey = "#text"
+)
+
+// decodeDocument decodes a JSON or XML document into a generic value.
+// JSON documents are decoded into maps, slices, strings, booleans and json.Number.
+// XML documents are decoded with decodeXmlTree.
+func decodeDocument(useXml bool, r io.Reader) (interface{}, error) {
+	if useXml {
+		return decodeXmlTree(r)
+	}
+
+	var document interface{}
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// decodeXmlTree decodes an XML document into a map holding the root element.
+// An element with child elements or attributes becomes a map keyed by child name
+// (attributes are prefixed by @, and the trimmed text of an element having attributes is kept
+// under #text), an element repeated under the same parent becomes a slice,
+// and an element without children becomes its trimmed text.
+//
+// Example:
+//
+//	<verification><code>a</code><code>b</code></verification>
+//	// is decoded into
+//	map[string]interface{}{"verification": map[string]interface{}{"code": []interface{}{"a", "b"}}}
+func decodeXmlTree(r io.Reader) (map[string]interface{}, error) {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("XML document has no root element")
+			}
+			return nil, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			value, err := decodeXmlElement(decoder, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{start.Name.Local: value}, nil
+		}
+	}
+}
+
+func decodeXmlElement(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	children := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		children[xmlAttributePrefix+attr.Name.Local] = attr.Value
+	}
+
+	var text strings.Builder
+	hasChildElements := false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			hasChildElements = true
+			value, err := decodeXmlElement(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			switch existing := children[name].(type) {
+			case nil:
+				children[name] = value
+			case []interface{}:
+				children[name] = append(existing, value)
+			default:
+				children[name] = []interface{}{existing, value}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			trimmed := strings.TrimSpace(text.String())
+			if !hasChildElements && len(children) == 0 {
+				return trimmed, nil
+			}
+			if len(start.Attr) > 0 && trimmed != "" {
+				children[xmlTextKey] = trimmed
+			}
+			return children, nil
+		}
+	}
+}
+
+// normalizeExpectedValue converts the expected value into the generic representation
+// produced by decodeDocument, so both can be compared with reflect.DeepEqual.
+// For XML, structs are marshaled and decoded again into the content of their root element,
+// whose name is returned, and the scalars of maps are converted to strings.
+func normalizeExpectedValue(useXml bool, expectedValue interface{}) (interface{}, string, error) {
+	isStruct := reflect.TypeOf(expectedValue).Kind() == reflect.Struct
+	if useXml && isStruct {
+		encoded, err := xml.Marshal(expectedValue)
+		if err != nil {
+			return nil, "", err
+		}
+		tree, err := decodeXmlTree(bytes.NewReader(encoded))
+		if err != nil {
+			return nil, "", err
+		}
+		rootName, content := xmlRoot(tree)
+		return content, rootName, nil
+	}
+
+	encoded, err := json.Marshal(expectedValue)
+	if err != nil {
+		return nil, "", err
+	}
+	normalized, err := decodeDocument(false, bytes.NewReader(encoded))
+	if err != nil {
+		return nil, "", err
+	}
+
+	// A struct shared with the XML method carries an XMLName field that is not part of the JSON document
+	if isStruct {
+		field, ok := reflect.TypeOf(expectedValue).FieldByName("XMLName")
+		if fields, isMap := normalized.(map[string]interface{}); ok && isMap && field.Tag.Get("json") == "" {
+			delete(fields, "XMLName")
+		}
+	}
+
+	if useXml {
+		return stringifyScalars(normalized), "", nil
+	}
+	return normalized, "", nil
+}
+
+// stringifyScalars converts the scalars of a generic JSON value into strings,
+// the only scalar type of a decoded XML document.
+func stringifyScalars(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = stringifyScalars(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = stringifyScalars(item)
+		}
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// xmlRoot returns the name and the content of the root element of a decoded XML document.
+func xmlRoot(document interface{}) (string, interface{}) {
+	if root, ok := document.(map[string]interface{}); ok && len(root) == 1 {
+		for name, content := range root {
+			return name, content
+		}
+	}
+	return "", document
+}
+
+// xmlLocalName returns the name of an element without its namespace prefix (e.g. verification for
+// ns:verification), as the decoded documents key the elements by their local name.
+func xmlLocalName(name string) string {
+	return name[strings.LastIndex(name, ":")+1:]
+}
+
+// isExactMatch reports whether actual is equal to expected, except that an expected value
+// other than a slice matches an actual slice containing it, so that a single code is found
+// among the several codes of a file (a JSON array or repeated XML elements).
+// Unlike isSubset, a map must have exactly the expected fields.
+func isExactMatch(expected, actual interface{}) bool {
+	if items, ok := actual.([]interface{}); ok {
+		if _, expectedSlice := expected.([]interface{}); !expectedSlice {
+			for _, item := range items {
+				if isExactMatch(expected, item) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	e, ok := expected.(map[string]interface{})
+	if !ok {
+		return reflect.DeepEqual(expected, actual)
+	}
+	a, ok := actual.(map[string]interface{})
+	if !ok || len(a) != len(e) {
+		return false
+	}
+	for key, expectedItem := range e {
+		actualItem, exists := a[key]
+		if !exists || !isExactMatch(expectedItem, actualItem) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSubset reports whether every field of expected is present in actual with an equal value.
+// Each element of an expected slice must match at least one element of the actual slice,
+// and any other expected value matches an actual slice containing it, so that a single code
+// is found among the several codes of a file (a JSON array or repeated XML elements).
+// An expected scalar also matches the text of an XML element having attributes.
+func isSubset(expected, actual interface{}) bool {
+	if items, ok := actual.([]interface{}); ok {
+		if _, expectedSlice := expected.([]interface{}); !expectedSlice {
+			for _, item := range items {
+				if isSubset(expected, item) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, expectedItem := range e {
+			actualItem, exists := a[key]
+			if !exists || !isSubset(expectedItem, actualItem) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, expectedItem := range e {
+			found := false
+			for _, actualItem := range a {
+				if isSubset(expectedItem, actualItem) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	default:
+		if a, ok := actual.(map[string]interface{}); ok {
+			if text, exists := a[xmlTextKey]; exists {
+				return reflect.DeepEqual(expected, text)
+			}
+		}
+		return reflect.DeepEqual(expected, actual)
+	}
+}
+
+// lookupKeyPath returns the value found at keyPath in a decoded document.
+// The segments of keyPath are separated by slashes (e.g. /verification/code)
+// or, when it contains no slash, by dots (e.g. verification.code).
+// A backslash escapes the next character, so that a segment can contain a slash or a dot
+// (e.g. myapp\.site_verification), see EscapeKeyPathSegment. A numeric segment indexes a slice.
+func lookupKeyPath(document interface{}, keyPath string) (interface{}, bool) {
+	current := document
+	for _, segment := range splitKeyPath(keyPath) {
+		if segment == "" {
+			continue
+		}
+		switch v := current.(type) {
+		case map[string]interface{}:
+			item, exists := v[segment]
+			if !exists {
+				return nil, false
+			}
+			current = item
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			current = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// splitKeyPath splits keyPath into unescaped segments, on its unescaped slashes,
+// or on its unescaped dots when it has no unescaped slash.
+func splitKeyPath(keyPath string) []string {
+	separator := '.'
+	escaped := false
+	for _, r := range keyPath {
+		if !escaped && r == '/' {
+			separator = '/'
+			break
+		}
+		escaped = !escaped && r == '\\'
+	}
+
+	var segments []string
+	var segment strings.Builder
+	escaped = false
+	for _, r := range keyPath {
+		switch {
+		case escaped:
+			segment.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == separator:
+			segments = append(segments, segment.String())
+			segment.Reset()
+		default:
+			segment.WriteRune(r)
+		}
+	}
+	return append(segments, segment.String())
+}
+
+// EscapeKeyPathSegment escapes the slashes, dots and backslashes of a key, so that it can be used
+// as a single segment of FileMatchOptions.KeyPath.
+//
+// Example:
+//
+//	keyPath := "/" + domainverifier.EscapeKeyPathSegment("myapp.site/verification")
+//	fmt.Println(keyPath)
+//	// Output:
+//	// /myapp\.site\/verification
+func EscapeKeyPathSegment(key string) string {
+	var sb strings.Builder
+	for _, r := range key {
+		if r == '/' || r == '.' || r == '\\' {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
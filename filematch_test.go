@@ -0,0 +1,278 @@
+package domainverifier
+
+import (
+	"strings"
+	"testing"
+)
+
+type sharedOwnershipVerification struct {
+	XMLName struct{} `xml:"verification"`
+	Code    string   `xml:"code" json:"myapp_site_verification"`
+}
+
+type listOwnershipVerification struct {
+	Codes []string `json:"codes"`
+}
+
+func TestMatchDocument(t *testing.T) {
+	type args struct {
+		useXml        bool
+		document      string
+		expectedValue interface{}
+		options       *FileMatchOptions
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			name: "json exact map",
+			args: args{false, `{"myapp_site_verification": "1234"}`,
+				map[string]string{"myapp_site_verification": "1234"}, &FileMatchOptions{}},
+			want: true,
+		},
+		{
+			name: "json exact map with extra field",
+			args: args{false, `{"myapp_site_verification": "1234", "other": 1}`,
+				map[string]string{"myapp_site_verification": "1234"}, &FileMatchOptions{}},
+			want: false,
+		},
+		{
+			name: "json subset map with extra field",
+			args: args{false, `{"myapp_site_verification": "1234", "other": 1}`,
+				map[string]string{"myapp_site_verification": "1234"}, &FileMatchOptions{Mode: FileMatchSubset}},
+			want: true,
+		},
+		{
+			name: "json subset struct shared with xml",
+			args: args{false, `{"myapp_site_verification": "1234", "other": 1}`,
+				sharedOwnershipVerification{Code: "1234"}, &FileMatchOptions{Mode: FileMatchSubset}},
+			want: true,
+		},
+		{
+			name: "json subset struct with slice",
+			args: args{false, `{"codes": ["a", "b", "c"]}`,
+				listOwnershipVerification{Codes: []string{"c", "a"}}, &FileMatchOptions{Mode: FileMatchSubset}},
+			want: true,
+		},
+		{
+			name: "json subset missing field",
+			args: args{false, `{"other": "1234"}`,
+				map[string]string{"myapp_site_verification": "1234"}, &FileMatchOptions{Mode: FileMatchSubset}},
+			want: false,
+		},
+		{
+			name: "json subset number",
+			args: args{false, `{"version": 2, "code": "1234"}`,
+				map[string]interface{}{"version": 2}, &FileMatchOptions{Mode: FileMatchSubset}},
+			want: true,
+		},
+		{
+			name: "json key path",
+			args: args{false, `{"myapp": {"verification": "1234"}}`,
+				"1234", &FileMatchOptions{Mode: FileMatchKeyPath, KeyPath: "myapp.verification"}},
+			want: true,
+		},
+		{
+			name: "json key path with index",
+			args: args{false, `{"codes": ["a", "1234"]}`,
+				"1234", &FileMatchOptions{Mode: FileMatchKeyPath, KeyPath: "/codes/1"}},
+			want: true,
+		},
+		{
+			name: "json key path with wrong value",
+			args: args{false, `{"myapp_site_verification": "1234"}`,
+				"4321", &FileMatchOptions{Mode: FileMatchKeyPath, KeyPath: "myapp_site_verification"}},
+			want: false,
+		},
+		{
+			name: "json key path not found",
+			args: args{false, `{"myapp_site_verification": "1234"}`,
+				"1234", &FileMatchOptions{Mode: FileMatchKeyPath, KeyPath: "other"}},
+			want: false,
+		},
+		{
+			name: "json key path among several codes",
+			args: args{false, `{"myapp_site_verification": ["a", "1234"]}`,
+				"1234", &FileMatchOptions{Mode: FileMatchKeyPath, KeyPath: "myapp_site_verification"}},
+			want: true,
+		},
+		{
+			name: "json key path not among several codes",
+			args: args{false, `{"myapp_site_verification": ["a", "b"]}`,
+				"1234", &FileMatchOptions{Mode: FileMatchKeyPath, KeyPath: "myapp_site_verification"}},
+			want: false,
+		},
+		{
+			name: "json subset struct among several codes",
+			args: args{false, `{"myapp_site_verification": ["a", "1234"]}`,
+				sharedOwnershipVerification{Code: "1234"}, &FileMatchOptions{Mode: FileMatchSubset}},
+			want: true,
+		},
+		{
+			name: "xml key path among repeated elements",
+			args: args{true, `<verification><code>a</code><code>1234</code></verification>`,
+				"1234", &FileMatchOptions{Mode: FileMatchKeyPath, KeyPath: "/verification/code"}},
+			want: true,
+		},
+		{
+			name: "xml subset struct among repeated elements",
+			args: args{true, `<verification><code>a</code><code>1234</code></verification>`,
+				sharedOwnershipVerification{Code: "1234"}, &FileMatchOptions{Mode: FileMatchSubset}},
+			want: true,
+		},
+		{
+			name: "json exact struct among several codes",
+			args: args{false, `{"myapp_site_verification": ["a", "1234"]}`,
+				sharedOwnershipVerification{Code: "1234"}, &FileMatchOptions{}},
+			want: true,
+		},
+		{
+			name: "json exact struct not among several codes",
+			args: args{false, `{"myapp_site_verification": ["a", "b"]}`,
+				sharedOwnershipVerification{Code: "1234"}, &FileMatchOptions{}},
+			want: false,
+		},
+		{
+			name: "json exact map among several codes with extra field",
+			args: args{false, `{"myapp_site_verification": ["a", "1234"], "other": 1}`,
+				map[string]string{"myapp_site_verification": "1234"}, &FileMatchOptions{}},
+			want: false,
+		},
+		{
+			name: "xml exact struct among repeated elements",
+			args: args{true, `<verification><code>a</code><code>1234</code></verification>`,
+				sharedOwnershipVerification{Code: "1234"}, &FileMatchOptions{}},
+			want: true,
+		},
+		{
+			name: "xml exact struct with wrong root",
+			args: args{true, `<other><code>a</code><code>1234</code></other>`,
+				sharedOwnershipVerification{Code: "1234"}, &FileMatchOptions{}},
+			want: false,
+		},
+		{
+			name: "xml exact map",
+			args: args{true, `<verification><code>1234</code></verification>`,
+				map[string]string{"code": "1234"}, &FileMatchOptions{}},
+			want: true,
+		},
+		{
+			name: "xml subset struct with extra element",
+			args: args{true, `<?xml version="1.0"?><verification><code>1234</code><owner>me</owner></verification>`,
+				sharedOwnershipVerification{Code: "1234"}, &FileMatchOptions{Mode: FileMatchSubset}},
+			want: true,
+		},
+		{
+			name: "xml subset map with number",
+			args: args{true, `<verification version="2"><code>1234</code></verification>`,
+				map[string]interface{}{"@version": 2}, &FileMatchOptions{Mode: FileMatchSubset}},
+			want: true,
+		},
+		{
+			name: "xml key path",
+			args: args{true, `<verification><code> 1234 </code></verification>`,
+				"1234", &FileMatchOptions{Mode: FileMatchKeyPath, KeyPath: "/verification/code"}},
+			want: true,
+		},
+		{
+			name: "xml key path to an element with attributes",
+			args: args{true, `<verification><code lang="en">CODE</code></verification>`,
+				"CODE", &FileMatchOptions{Mode: FileMatchKeyPath, KeyPath: "/verification/code"}},
+			want: true,
+		},
+		{
+			name: "xml subset struct with an element with attributes",
+			args: args{true, `<verification><code lang="en">CODE</code></verification>`,
+				sharedOwnershipVerification{Code: "CODE"}, &FileMatchOptions{Mode: FileMatchSubset}},
+			want: true,
+		},
+		{
+			name: "xml subset map with an element with attributes",
+			args: args{true, `<verification><code lang="en">CODE</code></verification>`,
+				map[string]string{"code": "CODE"}, &FileMatchOptions{Mode: FileMatchSubset, RootName: "verification"}},
+			want: true,
+		},
+		{
+			name: "xml subset element with attributes and another text",
+			args: args{true, `<verification><code lang="en">OTHER</code></verification>`,
+				map[string]string{"code": "CODE"}, &FileMatchOptions{Mode: FileMatchSubset, RootName: "verification"}},
+			want: false,
+		},
+		{
+			name: "xml subset struct with wrong root",
+			args: args{true, `<other><code>1234</code></other>`,
+				sharedOwnershipVerification{Code: "1234"}, &FileMatchOptions{Mode: FileMatchSubset}},
+			want: false,
+		},
+		{
+			name: "xml subset map with root name",
+			args: args{true, `<verification><code>1234</code></verification>`,
+				map[string]string{"code": "1234"}, &FileMatchOptions{Mode: FileMatchSubset, RootName: "verification"}},
+			want: true,
+		},
+		{
+			name: "xml exact map with wrong root name",
+			args: args{true, `<other><code>1234</code></other>`,
+				map[string]string{"code": "1234"}, &FileMatchOptions{RootName: "verification"}},
+			want: false,
+		},
+		{
+			name: "json key path with escaped dot",
+			args: args{false, `{"myapp.site_verification": "1234", "myapp": {"site_verification": "5678"}}`,
+				"1234", &FileMatchOptions{Mode: FileMatchKeyPath, KeyPath: `myapp\.site_verification`}},
+			want: true,
+		},
+		{
+			name: "json key path with escaped slash",
+			args: args{false, `{"myapp/site": {"code": "1234"}}`,
+				"1234", &FileMatchOptions{Mode: FileMatchKeyPath, KeyPath: "/" + EscapeKeyPathSegment("myapp/site") + "/code"}},
+			want: true,
+		},
+		{
+			name: "xml key path with wrong root",
+			args: args{true, `<other><code>1234</code></other>`,
+				"1234", &FileMatchOptions{Mode: FileMatchKeyPath, KeyPath: "/verification/code"}},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actualValue, err := decodeDocument(tt.args.useXml, strings.NewReader(tt.args.document))
+			if err != nil {
+				t.Fatalf("decodeDocument() error = %v", err)
+			}
+			expectedValue, rootName, err := normalizeExpectedValue(tt.args.useXml, tt.args.expectedValue)
+			if err != nil {
+				t.Fatalf("normalizeExpectedValue() error = %v", err)
+			}
+			if got := matchDocument(tt.args.useXml, actualValue, expectedValue, rootName, tt.args.options); got != tt.want {
+				t.Errorf("matchDocument() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateExpectedValue(t *testing.T) {
+	tests := []struct {
+		name          string
+		expectedValue interface{}
+		options       *FileMatchOptions
+		wantErr       bool
+	}{
+		{"struct", ownershipVerification{}, &FileMatchOptions{}, false},
+		{"map", map[string]string{}, &FileMatchOptions{Mode: FileMatchSubset}, false},
+		{"string in exact mode", "1234", &FileMatchOptions{}, true},
+		{"nil", nil, &FileMatchOptions{}, true},
+		{"string in key path mode", "1234", &FileMatchOptions{Mode: FileMatchKeyPath, KeyPath: "code"}, false},
+		{"empty key path", "1234", &FileMatchOptions{Mode: FileMatchKeyPath}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateExpectedValue(tt.expectedValue, tt.options); (err != nil) != tt.wantErr {
+				t.Errorf("validateExpectedValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
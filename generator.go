@@ -1,10 +1,13 @@
 package domainverifier
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/egbakou/domainverifier/config"
 	"github.com/segmentio/ksuid"
+	"net/http"
 	"strings"
 )
 
@@ -14,26 +17,52 @@ const (
 	xmlRootName              = "verification"
 	xmlFileNameSuffix        = "SiteAuth.xml"
 	txtRecordAttributeSuffix = "-site-verification"
+	textFileExtension        = ".html"
+	httpHeaderPrefix         = "X-"
+	challengeLabelPrefix     = "_"
+	challengeLabelSuffix     = "-challenge"
+	randomLabelBytes         = 8
 )
 
 // InvalidAppNameError indicates that the app name is invalid.
 var InvalidAppNameError = errors.New("app name cannot be empty")
 
+// RecordOptions records an instruction generated by GenerateHtmlMeta, GenerateJson, GenerateXml,
+// GenerateTextFile, GenerateHttpHeader, GenerateTxtRecord or GenerateTxtChallenge as a pending Challenge
+// of Domain, verified by the method of the instruction only.
+type RecordOptions struct {
+	Store     ChallengeStore // store recording the challenge, nothing is recorded when nil
+	Domain    string         // domain the instruction is issued for, required with Store
+	Account   string         // optional account the domain is verified for, recorded with the challenge
+	Challenge *Challenge     // set to the recorded challenge
+}
+
 // HtmlMetaInstruction is the Html meta tag instruction.
 type HtmlMetaInstruction struct {
 	Code   string
+	Path   string // page holding the meta tag, empty for the home page
 	Action string
 }
 
 // FileInstruction is the JSON or XML file instruction.
 type FileInstruction struct {
+	Method      Method // MethodJson, MethodXml or MethodTextFile
 	FileName    string
 	FileContent string
+	Path        string // URL path where the file must be served (e.g. /.well-known/myapp-site-verification.json)
+	Action      string
+}
+
+// HttpHeaderInstruction is the HTTP response header instruction.
+type HttpHeaderInstruction struct {
+	HeaderName  string
+	HeaderValue string
 	Action      string
 }
 
 // DnsRecordInstruction is the CNAME or TXT record instruction.
 type DnsRecordInstruction struct {
+	Method   Method // MethodTxtRecord or MethodCnameRecord
 	HostName string
 	Record   string
 	Action   string
@@ -52,17 +81,31 @@ func GenerateHtmlMetaFromConfig(config *config.HmlMetaTagGenerator, useInternalC
 		return nil, err
 	}
 
-	return &HtmlMetaInstruction{
-		Code:   getMetaTagContent(config.TagName, config.Code),
-		Action: getMetaTagInstruction(config.TagName, config.Code),
-	}, nil
+	instruction := &HtmlMetaInstruction{
+		Code: getMetaTagContent(config.TagName, config.Code),
+		Path: config.Path,
+	}
+	action, err := executeActionTemplate(config.ActionOptions, TemplateData{
+		Method:   MethodHtmlMeta,
+		Name:     config.TagName,
+		Code:     config.Code,
+		Codes:    []string{config.Code},
+		Content:  getMetaTagContent(config.TagName, config.Code),
+		FilePath: config.Path,
+	}, instruction)
+	if err != nil {
+		return nil, err
+	}
+	instruction.Action = action
+	return instruction, nil
 }
 
 // GenerateHtmlMeta generates the HTML meta tag verification method instructions.
 // appName is the name of the app that is requesting the verification (e.g. msvalidate.01, mysuperapp, etc.).
 // It will be used as the name of the meta tag.
 // Note that the appName will be sanitized to non-alphanumeric characters.
-func GenerateHtmlMeta(appName string, sanitizeAppName bool) (*HtmlMetaInstruction, error) {
+// The instruction is recorded as a pending Challenge when options holds a RecordOptions with a Store.
+func GenerateHtmlMeta(appName string, sanitizeAppName bool, options ...*RecordOptions) (*HtmlMetaInstruction, error) {
 	if strings.TrimSpace(appName) == "" {
 		return nil, InvalidAppNameError
 	}
@@ -73,13 +116,21 @@ func GenerateHtmlMeta(appName string, sanitizeAppName bool) (*HtmlMetaInstructio
 		TagName: appName,
 		Code:    ksuid.New().String(),
 	}
-	return GenerateHtmlMetaFromConfig(htmTagConfig, false)
+	instruction, err := GenerateHtmlMetaFromConfig(htmTagConfig, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordInstruction(options, MethodHtmlMeta, &config.Methods{HtmlMeta: htmTagConfig}); err != nil {
+		return nil, err
+	}
+	return instruction, nil
 }
 
 // GenerateJsonFromConfig generates the JSON verification method instructions.
 // It uses the provided config.JsonGenerator to generate the instructions.
 // If useInternalCode is true, internal K-Sortable Globally Unique ID will be generated.
 // Otherwise, the code in the config.JsonGenerator will be used.
+// When config.Codes is not empty, the attribute holds an array of all the codes.
 func GenerateJsonFromConfig(config *config.JsonGenerator, useInternalCode bool) (*FileInstruction, error) {
 	if config != nil && useInternalCode {
 		config.Code = ksuid.New().String()
@@ -90,18 +141,36 @@ func GenerateJsonFromConfig(config *config.JsonGenerator, useInternalCode bool)
 	}
 
 	fileName := ensureFileExtension(config.FileName, ".json")
-	return &FileInstruction{
+	filePath := ResolveFilePath(config.Path, fileName)
+	codes := config.VerificationCodes()
+	instruction := &FileInstruction{
+		Method:      MethodJson,
 		FileName:    config.FileName,
-		FileContent: getJsonContent(config.Attribute, config.Code),
-		Action:      getJsonInstruction(fileName, config.Attribute, config.Code),
-	}, nil
+		FileContent: getJsonContent(config.Attribute, codes),
+		Path:        filePath,
+	}
+	action, err := executeActionTemplate(config.ActionOptions, TemplateData{
+		Method:   MethodJson,
+		Name:     config.Attribute,
+		Code:     codes[0],
+		Codes:    codes,
+		Content:  getJsonContent(config.Attribute, codes),
+		FileName: fileName,
+		FilePath: filePath,
+	}, instruction)
+	if err != nil {
+		return nil, err
+	}
+	instruction.Action = action
+	return instruction, nil
 }
 
 // GenerateJson generates the JSON verification method instructions.
 // appName is the name of the app that is requesting the verification (e.g. google, bing, etc.).
 // It will be used as prefix of the file name and the attribute name.
 // Note that the appName will be sanitized to non-alphanumeric characters.
-func GenerateJson(appName string) (*FileInstruction, error) {
+// The instruction is recorded as a pending Challenge when options holds a RecordOptions with a Store.
+func GenerateJson(appName string, options ...*RecordOptions) (*FileInstruction, error) {
 	if strings.TrimSpace(appName) == "" {
 		return nil, InvalidAppNameError
 	}
@@ -113,13 +182,21 @@ func GenerateJson(appName string) (*FileInstruction, error) {
 		Attribute: fmt.Sprintf("%s%s", appName, jsonKeySuffix),
 		Code:      ksuid.New().String(),
 	}
-	return GenerateJsonFromConfig(jsonConfig, false)
+	instruction, err := GenerateJsonFromConfig(jsonConfig, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordInstruction(options, MethodJson, &config.Methods{Json: jsonConfig}); err != nil {
+		return nil, err
+	}
+	return instruction, nil
 }
 
 // GenerateXmlFromConfig generates the XML verification method instructions.
 // It uses the provided config.XmlGenerator to generate the instructions.
 // If useInternalCode is true, internal K-Sortable Globally Unique ID will be generated.
 // Otherwise, the code in the config.XmlGenerator will be used.
+// When config.Codes is not empty, the root element holds one <code> element per code.
 func GenerateXmlFromConfig(config *config.XmlGenerator, useInternalCode bool) (*FileInstruction, error) {
 	if config != nil && useInternalCode {
 		config.Code = ksuid.New().String()
@@ -130,18 +207,36 @@ func GenerateXmlFromConfig(config *config.XmlGenerator, useInternalCode bool) (*
 	}
 
 	fileName := ensureFileExtension(config.FileName, ".xml")
-	return &FileInstruction{
+	filePath := ResolveFilePath(config.Path, fileName)
+	codes := config.VerificationCodes()
+	instruction := &FileInstruction{
+		Method:      MethodXml,
 		FileName:    config.FileName,
-		FileContent: getXmlContent(config.RootName, config.Code),
-		Action:      getXmlInstruction(fileName, config.RootName, config.Code),
-	}, nil
+		FileContent: getXmlContent(config.RootName, codes),
+		Path:        filePath,
+	}
+	action, err := executeActionTemplate(config.ActionOptions, TemplateData{
+		Method:   MethodXml,
+		Name:     config.RootName,
+		Code:     codes[0],
+		Codes:    codes,
+		Content:  getXmlContent(config.RootName, codes),
+		FileName: fileName,
+		FilePath: filePath,
+	}, instruction)
+	if err != nil {
+		return nil, err
+	}
+	instruction.Action = action
+	return instruction, nil
 }
 
 // GenerateXml generates the XML verification method instructions.
 // appName is the name of the app that is requesting the verification (e.g. bing, google, etc.).
 // It will be used as prefix of the file name.
 // Note that the appName will be sanitized to non-alphanumeric characters.
-func GenerateXml(appName string, sanitizeAppName bool) (*FileInstruction, error) {
+// The instruction is recorded as a pending Challenge when options holds a RecordOptions with a Store.
+func GenerateXml(appName string, sanitizeAppName bool, options ...*RecordOptions) (*FileInstruction, error) {
 	if strings.TrimSpace(appName) == "" {
 		return nil, InvalidAppNameError
 	}
@@ -156,7 +251,136 @@ func GenerateXml(appName string, sanitizeAppName bool) (*FileInstruction, error)
 		RootName: xmlRootName,
 		Code:     ksuid.New().String(),
 	}
-	return GenerateXmlFromConfig(xmlConfig, false)
+	instruction, err := GenerateXmlFromConfig(xmlConfig, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordInstruction(options, MethodXml, &config.Methods{Xml: xmlConfig}); err != nil {
+		return nil, err
+	}
+	return instruction, nil
+}
+
+// GenerateTextFileFromConfig generates the plain-text file verification method instructions.
+// It uses the provided config.TextFileGenerator to generate the instructions.
+// If useInternalCode is true, internal K-Sortable Globally Unique ID will be generated as file content.
+// Otherwise, the Content in the config.TextFileGenerator will be used.
+func GenerateTextFileFromConfig(config *config.TextFileGenerator, useInternalCode bool) (*FileInstruction, error) {
+	if config != nil && useInternalCode {
+		config.Content = ksuid.New().String()
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	filePath := ResolveFilePath(config.Path, config.FileName)
+	instruction := &FileInstruction{
+		Method:      MethodTextFile,
+		FileName:    config.FileName,
+		FileContent: config.Content,
+		Path:        filePath,
+	}
+	action, err := executeActionTemplate(config.ActionOptions, TemplateData{
+		Method:   MethodTextFile,
+		Code:     config.Content,
+		Codes:    []string{config.Content},
+		Content:  config.Content,
+		FileName: config.FileName,
+		FilePath: filePath,
+	}, instruction)
+	if err != nil {
+		return nil, err
+	}
+	instruction.Action = action
+	return instruction, nil
+}
+
+// GenerateTextFile generates the plain-text file verification method instructions.
+// appName is the name of the app that is requesting the verification (e.g. google, bing, etc.).
+// The file name is the appName followed by a K-Sortable Globally Unique ID and the .html extension
+// (e.g. myapp2OjBkQ6Ub0FN0L5Wn5x8bHzoCKd.html), and the file content references the file name
+// (e.g. myapp-site-verification: myapp2OjBkQ6Ub0FN0L5Wn5x8bHzoCKd.html).
+// Note that the appName will be sanitized to non-alphanumeric characters.
+// The instruction is recorded as a pending Challenge when options holds a RecordOptions with a Store.
+func GenerateTextFile(appName string, options ...*RecordOptions) (*FileInstruction, error) {
+	if strings.TrimSpace(appName) == "" {
+		return nil, InvalidAppNameError
+	}
+
+	appName = sanitizeString(appName)
+	fileName := fmt.Sprintf("%s%s%s", appName, ksuid.New().String(), textFileExtension)
+
+	textConfig := &config.TextFileGenerator{
+		FileName: fileName,
+		Content:  fmt.Sprintf("%s%s: %s", appName, txtRecordAttributeSuffix, fileName),
+	}
+	instruction, err := GenerateTextFileFromConfig(textConfig, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordInstruction(options, MethodTextFile, &config.Methods{TextFile: textConfig}); err != nil {
+		return nil, err
+	}
+	return instruction, nil
+}
+
+// GenerateHttpHeaderFromConfig generates the HTTP response header verification method instructions.
+// It uses the provided config.HttpHeaderGenerator to generate the instructions.
+// If useInternalCode is true, internal K-Sortable Globally Unique ID will be generated.
+// Otherwise, the code in the config.HttpHeaderGenerator will be used.
+func GenerateHttpHeaderFromConfig(config *config.HttpHeaderGenerator, useInternalCode bool) (*HttpHeaderInstruction, error) {
+	if config != nil && useInternalCode {
+		config.Code = ksuid.New().String()
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	headerName := http.CanonicalHeaderKey(config.HeaderName)
+	instruction := &HttpHeaderInstruction{
+		HeaderName:  headerName,
+		HeaderValue: config.Code,
+	}
+	action, err := executeActionTemplate(config.ActionOptions, TemplateData{
+		Method:  MethodHttpHeader,
+		Name:    headerName,
+		Code:    config.Code,
+		Codes:   []string{config.Code},
+		Content: fmt.Sprintf("%s: %s", headerName, config.Code),
+	}, instruction)
+	if err != nil {
+		return nil, err
+	}
+	instruction.Action = action
+	return instruction, nil
+}
+
+// GenerateHttpHeader generates the HTTP response header verification method instructions.
+// appName is the name of the app that is requesting the verification (e.g. google, bing, etc.).
+// The header name is the appName prefixed by X- and suffixed by -Site-Verification (e.g. X-Myapp-Site-Verification).
+// Note that the appName will be sanitized to non-alphanumeric characters.
+// The instruction is recorded as a pending Challenge when options holds a RecordOptions with a Store.
+func GenerateHttpHeader(appName string, options ...*RecordOptions) (*HttpHeaderInstruction, error) {
+	if strings.TrimSpace(appName) == "" {
+		return nil, InvalidAppNameError
+	}
+
+	appName = sanitizeString(appName)
+
+	headerConfig := &config.HttpHeaderGenerator{
+		HeaderName: fmt.Sprintf("%s%s%s", httpHeaderPrefix, appName, txtRecordAttributeSuffix),
+		Code:       ksuid.New().String(),
+	}
+	instruction, err := GenerateHttpHeaderFromConfig(headerConfig, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordInstruction(options, MethodHttpHeader, &config.Methods{HttpHeader: headerConfig}); err != nil {
+		return nil, err
+	}
+	return instruction, nil
 }
 
 // GenerateTxtRecordFromConfig generates the TXT verification method instructions.
@@ -172,28 +396,112 @@ func GenerateTxtRecordFromConfig(config *config.TxtRecordGenerator, useInternalC
 		return nil, err
 	}
 
-	return &DnsRecordInstruction{
+	record := fmt.Sprintf("%s=%s", config.RecordAttribute, config.RecordAttributeValue)
+	instruction := &DnsRecordInstruction{
+		Method:   MethodTxtRecord,
 		HostName: config.HostName,
-		Record:   fmt.Sprintf("%s=%s", config.RecordAttribute, config.RecordAttributeValue),
-		Action: fmt.Sprintf(`Create a TXT record with the name %s and the content %s=%s`,
-			config.HostName, config.RecordAttribute, config.RecordAttributeValue),
-	}, nil
+		Record:   record,
+	}
+	action, err := executeActionTemplate(config.ActionOptions, TemplateData{
+		Method:     MethodTxtRecord,
+		Name:       config.RecordAttribute,
+		Code:       config.RecordAttributeValue,
+		Codes:      []string{config.RecordAttributeValue},
+		Content:    record,
+		HostName:   config.HostName,
+		Record:     record,
+		RecordType: "TXT",
+	}, instruction)
+	if err != nil {
+		return nil, err
+	}
+	instruction.Action = action
+	return instruction, nil
 }
 
 // GenerateTxtRecord generates the TXT verification method instructions.
 // appName is the name of the app that is requesting the verification (e.g. bing, google, etc.).
-// It will be used as prefix of the record attribute.
-func GenerateTxtRecord(appName string) (*DnsRecordInstruction, error) {
+// It will be used as prefix of the record attribute (e.g. MyApp-site-verification).
+// Note that the non-alphanumeric characters of the appName will be removed, the case is kept.
+// The instruction is recorded as a pending Challenge when options holds a RecordOptions with a Store.
+func GenerateTxtRecord(appName string, options ...*RecordOptions) (*DnsRecordInstruction, error) {
 	if strings.TrimSpace(appName) == "" {
 		return nil, InvalidAppNameError
 	}
 
+	appName = removeNonAlphanumeric(appName)
+	if appName == "" {
+		return nil, InvalidAppNameError
+	}
+
 	txtConfig := &config.TxtRecordGenerator{
 		HostName:             rootDomain,
 		RecordAttribute:      fmt.Sprintf("%s%s", appName, txtRecordAttributeSuffix),
 		RecordAttributeValue: ksuid.New().String(),
 	}
-	return GenerateTxtRecordFromConfig(txtConfig, false)
+	instruction, err := GenerateTxtRecordFromConfig(txtConfig, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordInstruction(options, MethodTxtRecord, &config.Methods{TxtRecord: txtConfig}); err != nil {
+		return nil, err
+	}
+	return instruction, nil
+}
+
+// GenerateTxtChallenge generates the TXT verification method instructions with a dedicated challenge host name
+// instead of the apex of the domain, so the record does not collide with SPF or the records of other vendors.
+// appName is the name of the app that is requesting the verification (e.g. bing, google, etc.).
+// The host name is the appName prefixed by an underscore and suffixed by -challenge (e.g. _myapp-challenge),
+// as ACME does with _acme-challenge. If randomLabel is true, a random suffix is added to the host name
+// (e.g. _myapp-challenge-3f9a1c0b7d2e4a56) so that each challenge has its own record.
+// The challenge host name can be delegated to another zone with a CNAME record, CheckTxtRecord follows it.
+// The appName is also the prefix of the record attribute, as with GenerateTxtRecord (e.g. MyApp-site-verification).
+// Note that the non-alphanumeric characters of the appName will be removed, and the host name is lowercased.
+// The instruction is recorded as a pending Challenge when options holds a RecordOptions with a Store.
+func GenerateTxtChallenge(appName string, randomLabel bool, options ...*RecordOptions) (*DnsRecordInstruction, error) {
+	if strings.TrimSpace(appName) == "" {
+		return nil, InvalidAppNameError
+	}
+
+	appName = removeNonAlphanumeric(appName)
+	if appName == "" {
+		return nil, InvalidAppNameError
+	}
+
+	hostName, err := challengeHostName(strings.ToLower(appName), randomLabel)
+	if err != nil {
+		return nil, err
+	}
+
+	txtConfig := &config.TxtRecordGenerator{
+		HostName:             hostName,
+		RecordAttribute:      fmt.Sprintf("%s%s", appName, txtRecordAttributeSuffix),
+		RecordAttributeValue: ksuid.New().String(),
+	}
+	instruction, err := GenerateTxtRecordFromConfig(txtConfig, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordInstruction(options, MethodTxtRecord, &config.Methods{TxtRecord: txtConfig}); err != nil {
+		return nil, err
+	}
+	return instruction, nil
+}
+
+// challengeHostName returns the challenge label of an app (e.g. _myapp-challenge),
+// followed by a random hexadecimal suffix if randomLabel is true.
+func challengeHostName(appName string, randomLabel bool) (string, error) {
+	hostName := fmt.Sprintf("%s%s%s", challengeLabelPrefix, appName, challengeLabelSuffix)
+	if !randomLabel {
+		return hostName, nil
+	}
+
+	suffix := make([]byte, randomLabelBytes)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s", hostName, hex.EncodeToString(suffix)), nil
 }
 
 // GenerateCnameRecordFromConfig generates the CNAME verification method instructions.
@@ -203,10 +511,41 @@ func GenerateCnameRecordFromConfig(config *config.CnameRecordGenerator) (*DnsRec
 		return nil, err
 	}
 
-	return &DnsRecordInstruction{
+	instruction := &DnsRecordInstruction{
+		Method:   MethodCnameRecord,
 		HostName: config.RecordName,
 		Record:   config.RecordTarget,
-		Action: fmt.Sprintf(`Add CNAME (alias) record with name %s and value %s.`,
-			config.RecordName, config.RecordTarget),
-	}, nil
+	}
+	action, err := executeActionTemplate(config.ActionOptions, TemplateData{
+		Method:     MethodCnameRecord,
+		Code:       config.RecordName,
+		Codes:      []string{config.RecordName},
+		Content:    config.RecordTarget,
+		HostName:   config.RecordName,
+		Record:     config.RecordTarget,
+		RecordType: "CNAME",
+	}, instruction)
+	if err != nil {
+		return nil, err
+	}
+	instruction.Action = action
+	return instruction, nil
+}
+
+// recordInstruction records the challenge of a generated instruction in the stores of the options.
+func recordInstruction(options []*RecordOptions, method Method, methods *config.Methods) error {
+	for _, o := range options {
+		if o == nil || o.Store == nil {
+			continue
+		}
+		if !IsValidDomainName(o.Domain) {
+			return InvalidDomainError
+		}
+		challenge, err := recordChallenge(o.Store, o.Domain, o.Account, method, methods)
+		if err != nil {
+			return err
+		}
+		o.Challenge = challenge
+	}
+	return nil
 }
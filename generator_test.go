@@ -3,10 +3,24 @@ package domainverifier
 import (
 	"errors"
 	"github.com/egbakou/domainverifier/config"
+	"github.com/segmentio/ksuid"
 	"strings"
 	"testing"
 )
 
+// assertGeneratedCode checks that got holds before, a code generated by ksuid, then after at its end.
+func assertGeneratedCode(t *testing.T, got, before, after string) {
+	t.Helper()
+	i := strings.Index(got, before)
+	if i < 0 || !strings.HasSuffix(got, after) || i+len(before) > len(got)-len(after) {
+		t.Errorf("expected: %s<code>%s, got: %v", before, after, got)
+		return
+	}
+	if _, err := ksuid.Parse(got[i+len(before) : len(got)-len(after)]); err != nil {
+		t.Errorf("expected a generated code in %v, got: %v", got, err)
+	}
+}
+
 func TestGenerateHtmlMetaFromConfig(t *testing.T) {
 	type args struct {
 		config          *config.HmlMetaTagGenerator
@@ -69,10 +83,109 @@ func TestGenerateHtmlMetaFromConfig(t *testing.T) {
 			if got != nil && !strings.Contains(got.Code, tt.want.Code) {
 				t.Errorf("expected: %v, got: %v", tt.want.Code, got.Code)
 			}
+			if got != nil && tt.args.useInternalCode {
+				assertGeneratedCode(t, got.Code, tt.want.Code+`"`, `" />`)
+			}
 		})
 	}
 }
 
+func TestGenerateHtmlMetaFromConfigWithPath(t *testing.T) {
+	got, err := GenerateHtmlMetaFromConfig(&config.HmlMetaTagGenerator{
+		TagName: "example-tag",
+		Code:    "external-code",
+		Path:    "/shop/",
+	}, false)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got.Path != "/shop/" {
+		t.Errorf("expected: %v, got: %v", "/shop/", got.Path)
+	}
+	if !strings.HasPrefix(got.Action, "Copy and paste the <meta> tag into the page /shop/ of your site.") {
+		t.Errorf("expected the instruction to mention the target page, got: %v", got.Action)
+	}
+}
+
+func TestGenerateFromConfigInternalCodeFillsConfig(t *testing.T) {
+	jsonConfig := &config.JsonGenerator{FileName: "example", Attribute: "code", Path: "/.well-known"}
+	instruction, err := GenerateJsonFromConfig(jsonConfig, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if jsonConfig.Code == "" || !strings.Contains(instruction.FileContent, jsonConfig.Code) {
+		t.Errorf("expected the config to hold the generated code, got code: %v", jsonConfig.Code)
+	}
+
+	txtConfig := &config.TxtRecordGenerator{HostName: "@", RecordAttribute: "myapp-site-verification"}
+	record, err := GenerateTxtRecordFromConfig(txtConfig, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if txtConfig.RecordAttributeValue == "" || !strings.HasSuffix(record.Record, txtConfig.RecordAttributeValue) {
+		t.Errorf("expected the config to hold the generated value, got value: %v", txtConfig.RecordAttributeValue)
+	}
+}
+
+func TestGenerateRecordOptions(t *testing.T) {
+	store := NewMemoryChallengeStore()
+	options := &RecordOptions{Store: store, Domain: "website.com", Account: "alice"}
+	instruction, err := GenerateTxtChallenge("myapp", false, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if options.Challenge == nil {
+		t.Fatalf("expected a recorded challenge")
+	}
+	stored, err := store.Get(options.Challenge.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored.Domain != "website.com" || stored.Account != "alice" || stored.Method != MethodTxtRecord ||
+		stored.Methods.TxtRecord.HostName != instruction.HostName || !strings.HasSuffix(instruction.Record, stored.Methods.TxtRecord.RecordAttributeValue) {
+		t.Errorf("expected the issued challenge, got: %+v", stored)
+	}
+
+	if _, err := GenerateJson("myapp", &RecordOptions{Store: store}); err != InvalidDomainError {
+		t.Errorf("expected: %v, got: %v", InvalidDomainError, err)
+	}
+	if _, err := GenerateJson("myapp", nil, &RecordOptions{Domain: "website.com"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if found, _ := store.FindByDomain("website.com", ""); len(found) != 1 {
+		t.Errorf("expected: %v, got: %v", 1, len(found))
+	}
+}
+
+func TestGenerateFromConfigLanguage(t *testing.T) {
+	jsonConfig := &config.JsonGenerator{FileName: "myapp.json", Attribute: "code", Code: "1234"}
+	jsonConfig.Language = "fr-CA"
+	instruction, err := GenerateJsonFromConfig(jsonConfig, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "Créez un fichier JSON nommé myapp.json avec le contenu :\n{\"code\": \"1234\"}\nTéléversez-le à la racine de votre site."
+	if instruction.Action != want {
+		t.Errorf("expected: %v, got: %v", want, instruction.Action)
+	}
+
+	txtConfig := &config.TxtRecordGenerator{HostName: "@", RecordAttribute: "myapp", RecordAttributeValue: "1234"}
+	txtConfig.Language = "de"
+	txtConfig.ActionTemplate = "{{.Default}}\nHilfe: https://help.myapp.com"
+	dnsInstruction, err := GenerateTxtRecordFromConfig(txtConfig, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(dnsInstruction.Action, "Erstellen Sie einen TXT-Eintrag") || !strings.HasSuffix(dnsInstruction.Action, "Hilfe: https://help.myapp.com") {
+		t.Errorf("expected the translated default action in the template, got: %v", dnsInstruction.Action)
+	}
+
+	txtConfig.Language = "not a language"
+	if _, err := GenerateTxtRecordFromConfig(txtConfig, false); err == nil {
+		t.Errorf("expected a validation error")
+	}
+}
+
 func TestGenerateHtmlMeta(t *testing.T) {
 	type args struct {
 		appName  string
@@ -198,10 +311,39 @@ func TestGenerateJsonFromConfig(t *testing.T) {
 			if got != nil && !strings.Contains(got.FileContent, tt.want.FileContent) {
 				t.Errorf("expected: %v, got: %v", tt.want.FileContent, got.FileContent)
 			}
+			if got != nil && tt.args.useInternalCode {
+				assertGeneratedCode(t, got.FileContent, tt.want.FileContent, `"}`)
+			}
 		})
 	}
 }
 
+func TestGenerateFileInstructionMultipleCodes(t *testing.T) {
+	jsonInstruction, err := GenerateJsonFromConfig(&config.JsonGenerator{
+		FileName:  "example.json",
+		Attribute: "code",
+		Codes:     []string{"account-1", "account-2"},
+	}, false)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if want := `{"code": ["account-1", "account-2"]}`; jsonInstruction.FileContent != want {
+		t.Errorf("expected: %v, got: %v", want, jsonInstruction.FileContent)
+	}
+
+	xmlInstruction, err := GenerateXmlFromConfig(&config.XmlGenerator{
+		FileName: "example.xml",
+		RootName: "verification",
+		Codes:    []string{"account-1"},
+	}, true)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !strings.HasSuffix(xmlInstruction.FileContent, "</code><code>account-1</code></verification>") {
+		t.Errorf("expected the internal code followed by the codes, got: %v", xmlInstruction.FileContent)
+	}
+}
+
 func TestGenerateJson(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -315,10 +457,46 @@ func TestGenerateXmlFromConfig(t *testing.T) {
 			if got != nil && !strings.Contains(got.FileContent, tt.want.FileContent) {
 				t.Errorf("expected: %v, got: %v", tt.want.FileContent, got.FileContent)
 			}
+			if got != nil && tt.args.useInternalCode {
+				assertGeneratedCode(t, got.FileContent, tt.want.FileContent, `</code></example-root>`)
+			}
 		})
 	}
 }
 
+func TestGenerateFileInstructionPath(t *testing.T) {
+	jsonInstruction, err := GenerateJsonFromConfig(&config.JsonGenerator{
+		FileName:  "myapp-verification",
+		Attribute: "code",
+		Code:      "external-code",
+		Path:      "/.well-known",
+	}, false)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if jsonInstruction.Path != "/.well-known/myapp-verification.json" {
+		t.Errorf("expected: %v, got: %v", "/.well-known/myapp-verification.json", jsonInstruction.Path)
+	}
+	if !strings.HasSuffix(jsonInstruction.Action, "served at /.well-known/myapp-verification.json.") {
+		t.Errorf("expected the instruction to name the full location, got: %v", jsonInstruction.Action)
+	}
+
+	xmlInstruction, err := GenerateXmlFromConfig(&config.XmlGenerator{
+		FileName: "example.xml",
+		RootName: "verification",
+		Code:     "external-code",
+	}, false)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if xmlInstruction.Path != "/example.xml" {
+		t.Errorf("expected: %v, got: %v", "/example.xml", xmlInstruction.Path)
+	}
+	if !strings.HasSuffix(xmlInstruction.Action, "Upload it to the root of your site.") {
+		t.Errorf("expected the root instruction, got: %v", xmlInstruction.Action)
+	}
+}
+
 func TestGenerateXml(t *testing.T) {
 	type args struct {
 		appName         string
@@ -381,6 +559,157 @@ func TestGenerateXml(t *testing.T) {
 	}
 }
 
+func TestGenerateTextFileFromConfig(t *testing.T) {
+	type args struct {
+		config          *config.TextFileGenerator
+		useInternalCode bool
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *FileInstruction
+		wantErr bool
+	}{
+		{
+			name: "Successful generation with external content",
+			args: args{
+				config: &config.TextFileGenerator{
+					FileName: "google1234.html",
+					Content:  "google-site-verification: google1234.html",
+				},
+				useInternalCode: false,
+			},
+			want: &FileInstruction{
+				FileName:    "google1234.html",
+				FileContent: "google-site-verification: google1234.html",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Successful generation with internal code",
+			args: args{
+				config: &config.TextFileGenerator{
+					FileName: "example.html",
+				},
+				useInternalCode: true,
+			},
+			want: &FileInstruction{
+				FileName:    "example.html",
+				FileContent: "",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Validation error",
+			args: args{
+				config:          nil,
+				useInternalCode: true,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateTextFileFromConfig(tt.args.config, tt.args.useInternalCode)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateTextFileFromConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got == nil {
+				return
+			}
+			if got.FileName != tt.want.FileName {
+				t.Errorf("expected: %v, got: %v", tt.want.FileName, got.FileName)
+			}
+			if got.FileContent == "" || !strings.Contains(got.FileContent, tt.want.FileContent) {
+				t.Errorf("expected: %v, got: %v", tt.want.FileContent, got.FileContent)
+			}
+			if tt.args.useInternalCode {
+				assertGeneratedCode(t, got.FileContent, tt.want.FileContent, "")
+			}
+		})
+	}
+}
+
+func TestGenerateTextFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "valid app name",
+			args:    "My App",
+			want:    "myapp-site-verification: myapp",
+			wantErr: false,
+		},
+		{
+			name:    "invalid app name",
+			args:    " ",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateTextFile(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateTextFile() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got == nil {
+				return
+			}
+			if !strings.HasPrefix(got.FileContent, tt.want) {
+				t.Errorf("expected: %v, got: %v", tt.want, got.FileContent)
+			}
+			if !strings.HasSuffix(got.FileContent, got.FileName) || !strings.HasSuffix(got.FileName, ".html") {
+				t.Errorf("unexpected file name %v for content %v", got.FileName, got.FileContent)
+			}
+		})
+	}
+}
+
+func TestGenerateHttpHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "valid app name",
+			args:    "My App",
+			want:    "X-Myapp-Site-Verification",
+			wantErr: false,
+		},
+		{
+			name:    "invalid app name",
+			args:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateHttpHeader(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateHttpHeader() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got == nil {
+				return
+			}
+			if got.HeaderName != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, got.HeaderName)
+			}
+			if got.HeaderValue == "" || !strings.Contains(got.Action, got.HeaderName+": "+got.HeaderValue) {
+				t.Errorf("unexpected instruction: %v", got.Action)
+			}
+		})
+	}
+}
+
 func TestGenerateTxtRecordFromConfig(t *testing.T) {
 	type args struct {
 		config          *config.TxtRecordGenerator
@@ -443,6 +772,9 @@ func TestGenerateTxtRecordFromConfig(t *testing.T) {
 			if got != nil && !strings.Contains(got.Record, tt.want.Record) {
 				t.Errorf("expected: %v, got: %v", tt.want.Record, got.Record)
 			}
+			if got != nil && tt.args.useInternalCode {
+				assertGeneratedCode(t, got.Record, tt.want.Record, "")
+			}
 		})
 	}
 }
@@ -468,6 +800,36 @@ func TestGenerateTxtRecord(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "App name casing is kept",
+			args: args{
+				appName: "MyApp",
+			},
+			want: &DnsRecordInstruction{
+				Record: "MyApp-site-verification=",
+			},
+			wantErr: false,
+		},
+		{
+			name: "App name with a space",
+			args: args{
+				appName: "My App",
+			},
+			want: &DnsRecordInstruction{
+				Record: "MyApp-site-verification=",
+			},
+			wantErr: false,
+		},
+		{
+			name: "App name with spaces and invalid characters",
+			args: args{
+				appName: " My App =\"v2\" ",
+			},
+			want: &DnsRecordInstruction{
+				Record: "MyAppv2-site-verification=",
+			},
+			wantErr: false,
+		},
 		{
 			name: "Validation error",
 			args: args{
@@ -476,6 +838,14 @@ func TestGenerateTxtRecord(t *testing.T) {
 			want:    nil,
 			wantErr: true,
 		},
+		{
+			name: "Only invalid characters",
+			args: args{
+				appName: "\"=\"",
+			},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -491,6 +861,66 @@ func TestGenerateTxtRecord(t *testing.T) {
 	}
 }
 
+func TestGenerateTxtChallenge(t *testing.T) {
+	type args struct {
+		appName     string
+		randomLabel bool
+	}
+	tests := []struct {
+		name         string
+		args         args
+		wantHostName string
+		wantRecord   string
+		wantErr      bool
+	}{
+		{
+			name:         "Fixed challenge label",
+			args:         args{appName: "My App", randomLabel: false},
+			wantHostName: "_myapp-challenge",
+			wantRecord:   "MyApp-site-verification=",
+		},
+		{
+			name:         "Random challenge label",
+			args:         args{appName: "myapp", randomLabel: true},
+			wantHostName: "_myapp-challenge-",
+			wantRecord:   "myapp-site-verification=",
+		},
+		{
+			name:    "Empty app name",
+			args:    args{appName: " "},
+			wantErr: true,
+		},
+		{
+			name:    "App name too long for a DNS label",
+			args:    args{appName: strings.Repeat("a", 50), randomLabel: true},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateTxtChallenge(tt.args.appName, tt.args.randomLabel)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateTxtChallenge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !strings.HasPrefix(got.HostName, tt.wantHostName) {
+				t.Errorf("expected: %v, got: %v", tt.wantHostName, got.HostName)
+			}
+			if tt.args.randomLabel && len(got.HostName) != len(tt.wantHostName)+2*randomLabelBytes {
+				t.Errorf("expected a random suffix, got: %v", got.HostName)
+			}
+			if !tt.args.randomLabel && got.HostName != tt.wantHostName {
+				t.Errorf("expected: %v, got: %v", tt.wantHostName, got.HostName)
+			}
+			if !strings.HasPrefix(got.Record, tt.wantRecord) {
+				t.Errorf("expected: %v, got: %v", tt.wantRecord, got.Record)
+			}
+		})
+	}
+}
+
 func TestGenerateCnameRecordFromConfig(t *testing.T) {
 	type args struct {
 		config *config.CnameRecordGenerator
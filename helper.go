@@ -1,61 +1,118 @@
 package domainverifier
 
 import (
-	"encoding/xml"
+	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/egbakou/domainverifier/config"
+	"html"
 	"net/http"
+	"net/url"
+	"path"
 	"regexp"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
+// DefaultHttpTimeout is the timeout of the HTTP requests of the checks, when no client is configured.
+const DefaultHttpTimeout = 30 * time.Second
+
+// defaultHttpClient sends the HTTP requests of the checks when no client is configured.
+var defaultHttpClient = &http.Client{Timeout: DefaultHttpTimeout}
+
 const (
 	domainNamePattern = `^([a-zA-Z0-9_]{1}[a-zA-Z0-9_-]{0,62}){1}(\.[a-zA-Z0-9_]{1}[a-zA-Z0-9_-]{0,62})*[\._]?$`
 	httpPrefix        = "http://"
 	httpsPrefix       = "https://"
 )
 
-func getMetaTagInstruction(name, content string) string {
-	var sb strings.Builder
-	sb.WriteString("Copy and paste the <meta> tag into your site's home page.\n")
-	sb.WriteString("It should go in the <head> section, before the first <body> section.\n")
-	sb.WriteString(getMetaTagContent(name, content))
-	sb.WriteString("\n* To stay verified, don't remove the meta tag even after verification succeeds.")
-	return sb.String()
+func getMetaTagContent(name, content string) string {
+	return fmt.Sprintf(`<meta name="%s" content="%s" />`, html.EscapeString(name), html.EscapeString(content))
 }
 
-func getMetaTagContent(name, content string) string {
-	return fmt.Sprintf(`<meta name="%s" content="%s" />`, name, content)
+// getJsonContent returns the JSON document holding the value, or the array of values when there are several.
+// The key and the values are encoded as JSON strings, so quotes and control characters are escaped.
+func getJsonContent(key string, values []string) string {
+	encodedValues := make([]string, len(values))
+	for i, value := range values {
+		encodedValues[i] = jsonString(value)
+	}
+
+	if len(encodedValues) == 1 {
+		return fmt.Sprintf(`{%s: %s}`, jsonString(key), encodedValues[0])
+	}
+	return fmt.Sprintf(`{%s: [%s]}`, jsonString(key), strings.Join(encodedValues, ", "))
 }
 
-func getJsonInstruction(filename, key, value string) string {
+// jsonString encodes a string as a JSON string literal.
+// HTML characters are kept as is (e.g. < rather than \u003c), so that the file content stays readable.
+func jsonString(str string) string {
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf(`Create a JSON file named %s with the content`, filename))
-	sb.WriteString("\n")
-	sb.WriteString(getJsonContent(key, value))
-	sb.WriteString("\nand upload it to the root of your site.")
-	return sb.String()
+	encoder := json.NewEncoder(&sb)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(str) // a string can always be encoded
+	return strings.TrimSuffix(sb.String(), "\n")
 }
 
-func getJsonContent(key, value string) string {
-	return fmt.Sprintf(`{"%s": "%s"}`, key, value)
+// getXmlContent returns the XML document holding one <code> element per code.
+func getXmlContent(rootName string, codes []string) string {
+	xmlConfig := &config.XmlGenerator{
+		RootName: rootName,
+		Codes:    codes,
+	}
+	return xmlConfig.ToXml()
 }
 
-func getXmlInstruction(xmlFileName, rootName, code string) string {
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf(`Create an XML file named %s with the content:`, xmlFileName))
-	sb.WriteString("\n")
-	sb.WriteString(getXmlContent(rootName, code))
-	sb.WriteString("\nand upload it to the root of your site.")
-	return sb.String()
+// ResolveFilePath returns the URL path where a verification file must be served.
+// pathTemplate is either empty (root of the site), a directory prefix (e.g. /.well-known)
+// or a path template containing config.FileNamePlaceholder (e.g. /.well-known/{fileName}).
+//
+// Example:
+//
+//	fmt.Println(ResolveFilePath("/.well-known", "myapp-verification.json"))
+//	// Output:
+//	// /.well-known/myapp-verification.json
+func ResolveFilePath(pathTemplate, fileName string) string {
+	pathTemplate = strings.TrimSpace(pathTemplate)
+	var filePath string
+	if strings.Contains(pathTemplate, config.FileNamePlaceholder) {
+		filePath = strings.ReplaceAll(pathTemplate, config.FileNamePlaceholder, fileName)
+	} else {
+		filePath = path.Join(pathTemplate, fileName)
+	}
+
+	if !strings.HasPrefix(filePath, "/") {
+		filePath = "/" + filePath
+	}
+	return filePath
 }
 
-func getXmlContent(rootName, code string) string {
-	var sb strings.Builder
-	sb.WriteString(xml.Header)
-	sb.WriteString(fmt.Sprintf(`<%s><code>%s</code></%s>`, rootName, code, rootName))
-	return sb.String()
+// pageLocation returns the location of a page on a domain, without the scheme.
+// page can be empty (home page), a URL path (e.g. /shop/) or a full URL,
+// in which case its host must be the domain.
+func pageLocation(domain, page string) (string, error) {
+	page = strings.TrimSpace(page)
+	if page == "" {
+		return domain, nil
+	}
+
+	u, err := url.Parse(page)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "" || u.Host != "") && !strings.EqualFold(u.Host, domain) {
+		return "", InvalidPageError
+	}
+
+	return fileLocation(domain, u.RequestURI()), nil
+}
+
+// fileLocation returns the location of a file on a domain, without the scheme.
+// filePath can be a bare file name or a path relative to the root of the site.
+func fileLocation(domain, filePath string) string {
+	return fmt.Sprintf("%s/%s", domain, strings.TrimLeft(filePath, "/"))
 }
 
 // IsValidDomainName checks if a string is a valid domain name.
@@ -99,19 +156,28 @@ func IsSecure(domain string, timeout time.Duration) (bool, error) {
 //	// Output:
 //	// mysuperapp
 func sanitizeString(str string) string {
-	// Trim the string
-	str = strings.TrimSpace(str)
+	return strings.ToLower(removeNonAlphanumeric(str))
+}
 
-	// Create a mapping function that removes non-alphabetic characters and spaces, and converts characters to lowercase
+// removeNonAlphanumeric removes all non-alphanumeric characters from a string, spaces included,
+// keeping the case of the letters.
+//
+// Example:
+//
+//	fmt.Println(removeNonAlphanumeric(" My App=v2 "))
+//	// Output:
+//	// MyAppv2
+func removeNonAlphanumeric(str string) string {
+	// Create a mapping function that removes non-alphabetic characters and spaces
 	mapping := func(r rune) rune {
 		if (!unicode.IsLetter(r) && !unicode.IsNumber(r)) || r == ' ' {
 			return -1
 		}
-		return unicode.ToLower(r)
+		return r
 	}
 
 	// Apply the mapping function to each character in the string
-	return strings.Map(mapping, str)
+	return strings.Map(mapping, strings.TrimSpace(str))
 }
 
 // ensureFileExtension ensures that a file name has a specific extension.
@@ -124,14 +190,104 @@ func ensureFileExtension(filename, extension string) string {
 	return filename
 }
 
-// makeHttpCall makes an HTTP call to the specified URL.
-func makeHttpCall(url string) (*http.Response, error) {
-	resp, err := http.Get(fmt.Sprintf("%s%s", httpsPrefix, url))
+// normalizeWhitespace trims a string and collapses every run of whitespace
+// characters (spaces, tabs, line breaks) into a single space.
+func normalizeWhitespace(str string) string {
+	return strings.Join(strings.Fields(str), " ")
+}
+
+// matchTextContent reports whether body matches expected according to the given mode.
+// Whitespace is normalized on both sides before comparison.
+func matchTextContent(body, expected string, mode TextMatchMode) bool {
+	body = normalizeWhitespace(body)
+	expected = normalizeWhitespace(expected)
+	if expected == "" {
+		return false
+	}
+
+	switch mode {
+	case TextMatchContains:
+		return containsToken(body, expected)
+	default:
+		return body == expected
+	}
+}
+
+// containsToken reports whether s contains substr at a token boundary: when substr starts or ends
+// with a token character (a letter, a digit, - or _), the adjacent character of s must not be one,
+// so that a code is not found inside a longer code.
+func containsToken(s, substr string) bool {
+	first, _ := utf8.DecodeRuneInString(substr)
+	last, _ := utf8.DecodeLastRuneInString(substr)
+
+	for offset := 0; offset < len(s); {
+		i := strings.Index(s[offset:], substr)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(substr)
+		before, _ := utf8.DecodeLastRuneInString(s[:start])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if !(isTokenRune(first) && isTokenRune(before)) && !(isTokenRune(last) && isTokenRune(after)) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(s[start:])
+		offset = start + size
+	}
+	return false
+}
+
+// isTokenRune reports whether r can be part of a code or a word.
+func isTokenRune(r rune) bool {
+	return r == '-' || r == '_' || (r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r)))
+}
+
+// matchHeaderValue reports whether one of the values of the named header is equal to value.
+// Each trimmed line is compared first, so that a value containing a comma is found,
+// then values combined into a single comma-separated line (e.g. by a proxy) are split before comparison.
+func matchHeaderValue(header http.Header, name, value string) bool {
+	for _, line := range header.Values(name) {
+		if strings.TrimSpace(line) == value {
+			return true
+		}
+		for _, v := range strings.Split(line, ",") {
+			if strings.TrimSpace(v) == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// makeHttpCall makes an HTTP GET call to the specified URL.
+func makeHttpCall(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	return makeHttpRequest(ctx, client, http.MethodGet, url)
+}
+
+// makeHttpRequest makes an HTTP request with the given method to the specified URL.
+// HTTPS is tried first, then HTTP unless the context is done.
+func makeHttpRequest(ctx context.Context, client *http.Client, method, url string) (*http.Response, error) {
+	resp, err := doHttpRequest(ctx, client, method, fmt.Sprintf("%s%s", httpsPrefix, url))
 	if err != nil {
-		resp, err = http.Get(fmt.Sprintf("%s%s", httpPrefix, url))
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		resp, err = doHttpRequest(ctx, client, method, fmt.Sprintf("%s%s", httpPrefix, url))
 		if err != nil {
 			return nil, err
 		}
 	}
 	return resp, nil
 }
+
+// doHttpRequest sends the request with the client, or with defaultHttpClient when it is nil.
+func doHttpRequest(ctx context.Context, client *http.Client, method, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = defaultHttpClient
+	}
+	return client.Do(req)
+}
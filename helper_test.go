@@ -2,6 +2,7 @@ package domainverifier
 
 import (
 	"errors"
+	"net/http"
 	"testing"
 	"time"
 )
@@ -83,3 +84,153 @@ func TestSanitizeString(t *testing.T) {
 		}
 	}
 }
+
+func TestMatchTextContent(t *testing.T) {
+	type args struct {
+		body     string
+		expected string
+		mode     TextMatchMode
+	}
+	testCases := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{"exact match", args{"google-site-verification: google1234.html", "google-site-verification: google1234.html", TextMatchExact}, true},
+		{"exact match with whitespace", args{"  google-site-verification:\tgoogle1234.html\r\n", "google-site-verification: google1234.html", TextMatchExact}, true},
+		{"exact mismatch", args{"google-site-verification: google1234.html", "google-site-verification", TextMatchExact}, false},
+		{"contains match", args{"<html>\n<body>code:  1234</body></html>", "code: 1234", TextMatchContains}, true},
+		{"contains longer code", args{"<html><body>code: 12345</body></html>", "code: 1234 ", TextMatchContains}, false},
+		{"contains code inside a word", args{"mycode: 1234", "code: 1234", TextMatchContains}, false},
+		{"contains code at a line boundary", args{"line one\ncode: 1234\nline three", "code: 1234", TextMatchContains}, true},
+		{"contains second occurrence", args{"code: 12345 code: 1234.", "code: 1234", TextMatchContains}, true},
+		{"contains expected starting with punctuation", args{"myapp=1234", "=1234", TextMatchContains}, true},
+		{"contains no match", args{"<html><body>code: 123</body></html>", "code: 1234", TextMatchContains}, false},
+		{"empty expected", args{"anything", "  ", TextMatchContains}, false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchTextContent(tt.args.body, tt.args.expected, tt.args.mode)
+			if got != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResolveFilePath(t *testing.T) {
+	type args struct {
+		pathTemplate string
+		fileName     string
+	}
+	testCases := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"root", args{"", "myapp.json"}, "/myapp.json"},
+		{"directory prefix", args{"/.well-known", "myapp.json"}, "/.well-known/myapp.json"},
+		{"directory prefix with trailing slash", args{".well-known/", "myapp.json"}, "/.well-known/myapp.json"},
+		{"template", args{"/.well-known/{fileName}", "myapp.json"}, "/.well-known/myapp.json"},
+		{"template with suffix", args{"/verify/{fileName}/index.html", "myapp"}, "/verify/myapp/index.html"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResolveFilePath(tt.args.pathTemplate, tt.args.fileName)
+			if got != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMatchHeaderValue(t *testing.T) {
+	header := http.Header{}
+	header.Add("X-Myapp-Site-Verification", "first-code")
+	header.Add("x-myapp-site-verification", "second-code, third-code")
+	header.Add("X-Other-Site-Verification", " code,with,commas ")
+
+	testCases := []struct {
+		name       string
+		headerName string
+		value      string
+		want       bool
+	}{
+		{"single value", "X-Myapp-Site-Verification", "first-code", true},
+		{"case-insensitive name", "x-myapp-site-verification", "first-code", true},
+		{"comma-separated value", "X-Myapp-Site-Verification", "third-code", true},
+		{"unknown value", "X-Myapp-Site-Verification", "other-code", false},
+		{"value with commas", "X-Other-Site-Verification", "code,with,commas", true},
+		{"part of a value with commas", "X-Other-Site-Verification", "with", true},
+		{"unknown header", "X-Unknown-Site-Verification", "first-code", false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchHeaderValue(header, tt.headerName, tt.value)
+			if got != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPageLocation(t *testing.T) {
+	type args struct {
+		domain string
+		page   string
+	}
+	testCases := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{"home page", args{"example.com", ""}, "example.com", false},
+		{"path", args{"example.com", "/shop/"}, "example.com/shop/", false},
+		{"relative path", args{"example.com", "shop/index.html"}, "example.com/shop/index.html", false},
+		{"path with query", args{"example.com", "/shop/?lang=fr"}, "example.com/shop/?lang=fr", false},
+		{"full url", args{"example.com", "https://Example.com/shop/"}, "example.com/shop/", false},
+		{"full url on another domain", args{"example.com", "https://evil.com/shop/"}, "", true},
+		{"full url on a subdomain", args{"example.com", "https://shop.example.com/"}, "", true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pageLocation(tt.args.domain, tt.args.page)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("pageLocation() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGeneratedSnippetsEscaping(t *testing.T) {
+	testCases := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"json", getJsonContent(`my"app`, []string{`a"b\c`}), `{"my\"app": "a\"b\\c"}`},
+		{"json array", getJsonContent("code", []string{"a", "<b>"}), `{"code": ["a", "<b>"]}`},
+		{"json html characters", getJsonContent("a&b", []string{"<c>&</c>"}), `{"a&b": "<c>&</c>"}`},
+		{"xml", getXmlContent("verification", []string{"a<b&c"}),
+			`<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<verification><code>a&lt;b&amp;c</code></verification>`},
+		{"html meta", getMetaTagContent(`my"app`, `"><script>`),
+			`<meta name="my&#34;app" content="&#34;&gt;&lt;script&gt;" />`},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, tt.got)
+			}
+		})
+	}
+}
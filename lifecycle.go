@@ -0,0 +1,289 @@
+package domainverifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultChallengeTTL is the time a challenge stays pending before it expires, when no TTL is configured.
+	DefaultChallengeTTL = 7 * 24 * time.Hour
+	// DefaultMaxAttempts is the number of failed attempts after which a challenge fails, when none is configured.
+	DefaultMaxAttempts = 10
+)
+
+var (
+	// InvalidTransitionError indicates that the status of a challenge cannot change to the requested status,
+	// e.g. an expired challenge cannot be verified.
+	InvalidTransitionError = errors.New("invalid challenge status transition")
+
+	// ChallengeNotPendingError indicates that a verification was attempted on a challenge that is not pending.
+	ChallengeNotPendingError = errors.New("the challenge is not pending")
+
+	// ChallengeNotVerifiedError indicates that a re-check was attempted on a challenge that is not verified.
+	ChallengeNotVerifiedError = errors.New("the challenge is not verified")
+
+	// NotVerifiedError is recorded as the last error of an attempt that found no valid verification.
+	NotVerifiedError = errors.New("the ownership of the domain could not be verified")
+)
+
+// challengeTransitions lists the statuses each status can change to.
+// The failed, expired, revoked and lost statuses are final: a new challenge has to be issued.
+var challengeTransitions = map[ChallengeStatus][]ChallengeStatus{
+	ChallengePending:  {ChallengeVerified, ChallengeFailed, ChallengeExpired, ChallengeRevoked},
+	ChallengeVerified: {ChallengeRevoked, ChallengeLost},
+}
+
+// CanTransition reports whether a challenge with the status from can change to the status to.
+func CanTransition(from, to ChallengeStatus) bool {
+	for _, status := range challengeTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ChallengeEvent is emitted by a LifecycleManager when the status of a challenge changes.
+type ChallengeEvent struct {
+	Challenge  *Challenge // the challenge after the transition
+	Transition Transition
+}
+
+// LifecycleOptions configures a LifecycleManager.
+type LifecycleOptions struct {
+	TTL         time.Duration // time a challenge stays pending before it expires, DefaultChallengeTTL when 0
+	MaxAttempts int           // failed attempts after which a challenge fails, DefaultMaxAttempts when 0
+	DnsResolver string        // DNS server of the TXT and CNAME checks, dnsresolver.CloudflareDNS when empty
+	HttpClient  *http.Client  // client of the HTTP checks, a client with DefaultHttpTimeout when nil
+}
+
+// LifecycleManager moves the challenges of a ChallengeStore through their statuses:
+// pending challenges become verified, failed after too many attempts, or expired after their TTL,
+// and verified challenges can be revoked. Every transition is recorded in the history of the challenge
+// and emitted as a ChallengeEvent to the handlers registered with OnTransition.
+type LifecycleManager struct {
+	store    ChallengeStore
+	options  LifecycleOptions
+	now      func() time.Time
+	mu       sync.Mutex // serializes the changes of the challenges
+	handlers []func(ChallengeEvent)
+	hmu      sync.RWMutex
+}
+
+// NewLifecycleManager returns a manager of the challenges of the store. options can be nil.
+//
+// Example:
+//
+//	manager := domainverifier.NewLifecycleManager(store, &domainverifier.LifecycleOptions{TTL: 72 * time.Hour})
+//	manager.OnTransition(func(event domainverifier.ChallengeEvent) {
+//		log.Printf("%s: %s -> %s", event.Challenge.Domain, event.Transition.From, event.Transition.To)
+//	})
+//	challenge, err := manager.Attempt(challengeID)
+func NewLifecycleManager(store ChallengeStore, options *LifecycleOptions) *LifecycleManager {
+	m := &LifecycleManager{store: store, now: time.Now}
+	if options != nil {
+		m.options = *options
+	}
+	if m.options.TTL <= 0 {
+		m.options.TTL = DefaultChallengeTTL
+	}
+	if m.options.MaxAttempts <= 0 {
+		m.options.MaxAttempts = DefaultMaxAttempts
+	}
+	return m
+}
+
+// OnTransition registers a handler called after each status transition, once the challenge is stored.
+// The handlers are called synchronously, in the order they were registered.
+func (m *LifecycleManager) OnTransition(handler func(ChallengeEvent)) {
+	m.hmu.Lock()
+	defer m.hmu.Unlock()
+	m.handlers = append(m.handlers, handler)
+}
+
+// ExpiresAt returns the time the challenge expires if it is still pending.
+func (m *LifecycleManager) ExpiresAt(challenge *Challenge) time.Time {
+	return challenge.CreatedAt.Add(m.options.TTL)
+}
+
+// Attempt checks the ownership of the domain of a pending challenge and records the attempt.
+// The challenge becomes verified when the check succeeds, failed when the maximum number of attempts is reached,
+// and expired, without being checked, when its TTL has elapsed. ChallengeNotPendingError is returned,
+// along with the challenge, when it is not pending.
+func (m *LifecycleManager) Attempt(id string) (*Challenge, error) {
+	challenge, err := m.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.Status != ChallengePending {
+		return challenge, ChallengeNotPendingError
+	}
+	if m.expired(challenge) {
+		return m.Transition(id, ChallengeExpired, "the challenge expired")
+	}
+
+	// the check is done without holding the lock, the challenge is read again before it is updated
+	method, verified, checkErr := challenge.check(context.Background(), m.checkOptions())
+
+	return m.update(id, func(c *Challenge) (ChallengeStatus, string, error) {
+		if c.Status != ChallengePending {
+			return c.Status, "", ChallengeNotPendingError
+		}
+		c.Attempts++
+		if verified {
+			c.LastError = ""
+			return ChallengeVerified, fmt.Sprintf("verified with the %s method", method), nil
+		}
+
+		if checkErr == nil {
+			checkErr = NotVerifiedError
+		}
+		c.LastError = checkErr.Error()
+		if c.Attempts >= m.options.MaxAttempts {
+			return ChallengeFailed, fmt.Sprintf("%d attempts failed", c.Attempts), nil
+		}
+		return ChallengePending, "", nil
+	})
+}
+
+// Recheck checks again the ownership of the domain of a verified challenge, e.g. to detect that the domain
+// expired or changed hands. A failed re-check is tolerated until maxFailures consecutive re-checks failed:
+// the challenge is then lost. maxFailures is 1 when lower. ChallengeNotVerifiedError is returned,
+// along with the challenge, when it is not verified. The context cancels the requests of the re-check:
+// the context error is then returned and the re-check is not recorded.
+func (m *LifecycleManager) Recheck(ctx context.Context, id string, maxFailures int) (*Challenge, error) {
+	challenge, err := m.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.Status != ChallengeVerified {
+		return challenge, ChallengeNotVerifiedError
+	}
+
+	_, verified, checkErr := challenge.check(ctx, m.checkOptions())
+	if err := ctx.Err(); err != nil {
+		return challenge, err
+	}
+
+	return m.update(id, func(c *Challenge) (ChallengeStatus, string, error) {
+		if c.Status != ChallengeVerified {
+			return c.Status, "", ChallengeNotVerifiedError
+		}
+		c.CheckedAt = m.now().UTC()
+		if verified {
+			c.RecheckFailures = 0
+			c.LastError = ""
+			return ChallengeVerified, "", nil
+		}
+
+		if checkErr == nil {
+			checkErr = NotVerifiedError
+		}
+		c.RecheckFailures++
+		c.LastError = checkErr.Error()
+		if c.RecheckFailures >= maxFailures {
+			return ChallengeLost, fmt.Sprintf("%d consecutive re-checks failed", c.RecheckFailures), nil
+		}
+		return ChallengeVerified, "", nil
+	})
+}
+
+// Transition changes the status of the challenge, recording the reason in its history.
+// InvalidTransitionError is returned when the status cannot change to the requested one (see CanTransition).
+func (m *LifecycleManager) Transition(id string, to ChallengeStatus, reason string) (*Challenge, error) {
+	return m.update(id, func(c *Challenge) (ChallengeStatus, string, error) {
+		if !CanTransition(c.Status, to) {
+			return c.Status, "", InvalidTransitionError
+		}
+		return to, reason, nil
+	})
+}
+
+// Revoke revokes a pending or verified challenge, e.g. when the domain changed hands.
+func (m *LifecycleManager) Revoke(id, reason string) (*Challenge, error) {
+	return m.Transition(id, ChallengeRevoked, reason)
+}
+
+// ExpirePending expires the pending challenges whose TTL has elapsed and returns them.
+func (m *LifecycleManager) ExpirePending() ([]*Challenge, error) {
+	pending, err := m.store.ListPending()
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []*Challenge
+	for _, challenge := range pending {
+		if !m.expired(challenge) {
+			continue
+		}
+		challenge, err := m.Transition(challenge.ID, ChallengeExpired, "the challenge expired")
+		if errors.Is(err, InvalidTransitionError) {
+			// the challenge changed since it was listed
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired = append(expired, challenge)
+	}
+	return expired, nil
+}
+
+func (m *LifecycleManager) checkOptions() checkOptions {
+	return checkOptions{dnsResolver: m.options.DnsResolver, httpClient: m.options.HttpClient}
+}
+
+func (m *LifecycleManager) expired(challenge *Challenge) bool {
+	return !m.now().Before(m.ExpiresAt(challenge))
+}
+
+// update applies a change to the stored challenge and records the status it returns.
+// The challenge is stored even when the status is unchanged, e.g. to record an attempt.
+func (m *LifecycleManager) update(id string, apply func(c *Challenge) (ChallengeStatus, string, error)) (*Challenge, error) {
+	m.mu.Lock()
+	challenge, err := m.store.Get(id)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+
+	from := challenge.Status
+	to, reason, err := apply(challenge)
+	if err != nil {
+		m.mu.Unlock()
+		return challenge, err
+	}
+
+	now := m.now().UTC()
+	var transition *Transition
+	if to != from {
+		transition = &Transition{From: from, To: to, At: now, Reason: reason}
+		challenge.Status = to
+		challenge.History = append(challenge.History, *transition)
+	}
+	challenge.UpdatedAt = now
+	err = m.store.Update(challenge)
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if transition != nil {
+		m.emit(ChallengeEvent{Challenge: copyChallenge(challenge), Transition: *transition})
+	}
+	return challenge, nil
+}
+
+func (m *LifecycleManager) emit(event ChallengeEvent) {
+	m.hmu.RLock()
+	handlers := append(([]func(ChallengeEvent))(nil), m.handlers...)
+	m.hmu.RUnlock()
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
@@ -0,0 +1,160 @@
+package domainverifier
+
+import (
+	"github.com/egbakou/domainverifier/config"
+	"testing"
+	"time"
+)
+
+func newTestLifecycle(t *testing.T, options *LifecycleOptions) (*LifecycleManager, *[]ChallengeEvent, *time.Time) {
+	t.Helper()
+	if options.DnsResolver == "" {
+		options.DnsResolver = startTestDNSServer(t, map[string][]string{
+			"_myapp-challenge.website.com.": {`_myapp-challenge.website.com. 60 IN TXT "myapp=1234"`},
+		})
+	}
+	manager := NewLifecycleManager(NewMemoryChallengeStore(), options)
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	manager.now = func() time.Time { return now }
+
+	var events []ChallengeEvent
+	manager.OnTransition(func(event ChallengeEvent) {
+		events = append(events, event)
+	})
+	return manager, &events, &now
+}
+
+func createTestChallenge(t *testing.T, manager *LifecycleManager, domain string, createdAt time.Time) string {
+	t.Helper()
+	challenge := &Challenge{
+		Domain:    domain,
+		Method:    MethodTxtRecord,
+		Methods:   &config.Methods{TxtRecord: &config.TxtRecordGenerator{HostName: "_myapp-challenge", RecordAttribute: "myapp", RecordAttributeValue: "1234"}},
+		CreatedAt: createdAt,
+	}
+	if err := manager.store.Create(challenge); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return challenge.ID
+}
+
+func TestLifecycleManagerAttempt(t *testing.T) {
+	manager, events, now := newTestLifecycle(t, &LifecycleOptions{MaxAttempts: 2})
+
+	verifiedID := createTestChallenge(t, manager, "website.com", *now)
+	challenge, err := manager.Attempt(verifiedID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if challenge.Status != ChallengeVerified || challenge.Attempts != 1 || len(challenge.History) != 1 {
+		t.Errorf("expected a verified challenge, got: %+v", challenge)
+	}
+	if _, err := manager.Attempt(verifiedID); err != ChallengeNotPendingError {
+		t.Errorf("expected: %v, got: %v", ChallengeNotPendingError, err)
+	}
+
+	failedID := createTestChallenge(t, manager, "other.com", *now)
+	challenge, err = manager.Attempt(failedID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if challenge.Status != ChallengePending || challenge.Attempts != 1 || challenge.LastError != NotVerifiedError.Error() {
+		t.Errorf("expected a pending challenge with an error, got: %+v", challenge)
+	}
+	challenge, _ = manager.Attempt(failedID)
+	if challenge.Status != ChallengeFailed || challenge.Attempts != 2 {
+		t.Errorf("expected a failed challenge, got: %+v", challenge)
+	}
+
+	if len(*events) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(*events))
+	}
+	if e := (*events)[0]; e.Challenge.ID != verifiedID || e.Transition.From != ChallengePending || e.Transition.To != ChallengeVerified ||
+		!e.Transition.At.Equal(*now) {
+		t.Errorf("unexpected event: %+v", e)
+	}
+	if e := (*events)[1]; e.Challenge.ID != failedID || e.Transition.To != ChallengeFailed {
+		t.Errorf("unexpected event: %+v", e)
+	}
+}
+
+func TestLifecycleManagerExpiry(t *testing.T) {
+	manager, events, now := newTestLifecycle(t, &LifecycleOptions{TTL: time.Hour})
+
+	oldID := createTestChallenge(t, manager, "website.com", now.Add(-2*time.Hour))
+	recentID := createTestChallenge(t, manager, "website.com", now.Add(-30*time.Minute))
+	staleID := createTestChallenge(t, manager, "other.com", now.Add(-time.Hour))
+
+	challenge, err := manager.Attempt(oldID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if challenge.Status != ChallengeExpired || challenge.Attempts != 0 {
+		t.Errorf("expected an expired challenge without attempt, got: %+v", challenge)
+	}
+
+	expired, err := manager.ExpirePending()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(expired) != 1 || expired[0].ID != staleID {
+		t.Errorf("expected the stale challenge only, got: %v", expired)
+	}
+	if challenge, _ := manager.store.Get(recentID); challenge.Status != ChallengePending {
+		t.Errorf("expected: %v, got: %v", ChallengePending, challenge.Status)
+	}
+	if len(*events) != 2 {
+		t.Errorf("expected: %v, got: %v", 2, len(*events))
+	}
+	if got := manager.ExpiresAt(&Challenge{CreatedAt: *now}); !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected: %v, got: %v", now.Add(time.Hour), got)
+	}
+}
+
+func TestLifecycleManagerTransition(t *testing.T) {
+	manager, events, now := newTestLifecycle(t, &LifecycleOptions{})
+	id := createTestChallenge(t, manager, "website.com", *now)
+
+	if _, err := manager.Transition(id, ChallengePending, ""); err != InvalidTransitionError {
+		t.Errorf("expected: %v, got: %v", InvalidTransitionError, err)
+	}
+	if _, err := manager.Transition(id, ChallengeVerified, "verified by support"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	challenge, err := manager.Revoke(id, "the domain changed hands")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if challenge.Status != ChallengeRevoked || len(challenge.History) != 2 || challenge.History[1].Reason != "the domain changed hands" {
+		t.Errorf("unexpected challenge: %+v", challenge)
+	}
+	if _, err := manager.Transition(id, ChallengeVerified, ""); err != InvalidTransitionError {
+		t.Errorf("expected: %v, got: %v", InvalidTransitionError, err)
+	}
+	if _, err := manager.Revoke("unknown", ""); err != ChallengeNotFoundError {
+		t.Errorf("expected: %v, got: %v", ChallengeNotFoundError, err)
+	}
+	if len(*events) != 2 {
+		t.Errorf("expected: %v, got: %v", 2, len(*events))
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to ChallengeStatus
+		want     bool
+	}{
+		{ChallengePending, ChallengeVerified, true},
+		{ChallengePending, ChallengeExpired, true},
+		{ChallengeVerified, ChallengeRevoked, true},
+		{ChallengeVerified, ChallengePending, false},
+		{ChallengeFailed, ChallengeVerified, false},
+		{ChallengeExpired, ChallengePending, false},
+		{ChallengeRevoked, ChallengeVerified, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("%s -> %s, expected: %v, got: %v", tt.from, tt.to, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package domainverifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"strings"
+	"time"
+)
+
+// DNSProvider creates and removes the DNS record of a TXT or CNAME instruction through the API of the DNS provider
+// of the domain, so that the domain is verified without action of its owner.
+type DNSProvider interface {
+	// Present creates the record of the instruction for the domain.
+	Present(domain string, instruction *DnsRecordInstruction) error
+	// CleanUp removes the record created by Present, once the domain is verified.
+	CleanUp(domain string, instruction *DnsRecordInstruction) error
+}
+
+// RFC2136Provider is a DNSProvider sending RFC 2136 dynamic updates to the primary name server of the zone,
+// optionally signed with a TSIG key.
+type RFC2136Provider struct {
+	Nameserver    string        // address of the primary name server, e.g. ns1.website.com:53
+	Zone          string        // zone to update, the domain when empty
+	TSIGKeyName   string        // name of the TSIG key, updates are not signed when empty
+	TSIGSecret    string        // base64 secret of the TSIG key
+	TSIGAlgorithm string        // dns.HmacSHA256 when empty
+	TTL           uint32        // TTL of the created records, DefaultTTL when 0
+	Timeout       time.Duration // timeout of the updates, 10 seconds when 0
+}
+
+// DnsUpdateError indicates that the name server refused a dynamic update.
+var DnsUpdateError = errors.New("the name server refused the dynamic update")
+
+const defaultDnsUpdateTimeout = 10 * time.Second
+
+// Present adds the record of the instruction to the zone.
+//
+// Example:
+//
+//	provider := &domainverifier.RFC2136Provider{
+//		Nameserver:  "ns1.website.com:53",
+//		TSIGKeyName: "myapp-key",
+//		TSIGSecret:  "c2VjcmV0...",
+//	}
+//	err := provider.Present("website.com", instruction)
+func (p *RFC2136Provider) Present(domain string, instruction *DnsRecordInstruction) error {
+	return p.update(domain, instruction, false)
+}
+
+// CleanUp removes the record of the instruction from the zone, keeping the other records of the same name.
+func (p *RFC2136Provider) CleanUp(domain string, instruction *DnsRecordInstruction) error {
+	return p.update(domain, instruction, true)
+}
+
+func (p *RFC2136Provider) update(domain string, instruction *DnsRecordInstruction, remove bool) error {
+	if strings.TrimSpace(p.Nameserver) == "" {
+		return errors.New("name server cannot be empty")
+	}
+
+	record, err := NewDnsRecord(instruction, domain, p.TTL)
+	if err != nil {
+		return err
+	}
+	rr, err := dns.NewRR(record.ZoneFile())
+	if err != nil {
+		return err
+	}
+
+	zone := p.Zone
+	if strings.TrimSpace(zone) == "" {
+		zone = domain
+	}
+
+	m := new(dns.Msg)
+	m.SetUpdate(dns.Fqdn(zone))
+	if remove {
+		m.Remove([]dns.RR{rr})
+	} else {
+		m.Insert([]dns.RR{rr})
+	}
+
+	c := dns.Client{Timeout: p.Timeout}
+	if c.Timeout == 0 {
+		c.Timeout = defaultDnsUpdateTimeout
+	}
+	if p.TSIGKeyName != "" {
+		algorithm := p.TSIGAlgorithm
+		if algorithm == "" {
+			algorithm = dns.HmacSHA256
+		}
+		keyName := dns.Fqdn(p.TSIGKeyName)
+		c.TsigSecret = map[string]string{keyName: p.TSIGSecret}
+		m.SetTsig(keyName, dns.Fqdn(algorithm), 300, time.Now().Unix())
+	}
+
+	r, _, err := c.Exchange(m, p.Nameserver)
+	if err != nil {
+		return err
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("%w: %s", DnsUpdateError, dns.RcodeToString[r.Rcode])
+	}
+	return nil
+}
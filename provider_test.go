@@ -0,0 +1,141 @@
+package domainverifier
+
+import (
+	"errors"
+	"github.com/miekg/dns"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testTsigSecret = "c2VjcmV0LXRzaWcta2V5LWZvci10ZXN0cw=="
+
+// startTestUpdateServer starts a name server for the zone accepting the dynamic updates signed with the key
+// myapp-key. and answering the queries from the updated records.
+func startTestUpdateServer(t *testing.T, zone string) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+
+	var mu sync.Mutex
+	var records []dns.RR
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		m := new(dns.Msg)
+		m.SetReply(r)
+		switch {
+		case r.Opcode != dns.OpcodeUpdate:
+			for _, rr := range records {
+				if strings.EqualFold(rr.Header().Name, r.Question[0].Name) {
+					m.Answer = append(m.Answer, rr)
+				}
+			}
+			if len(m.Answer) == 0 {
+				m.Rcode = dns.RcodeNameError
+			}
+		case r.IsTsig() == nil || w.TsigStatus() != nil:
+			m.Rcode = dns.RcodeRefused
+		case !strings.EqualFold(r.Question[0].Name, zone):
+			m.Rcode = dns.RcodeNotZone
+		default:
+			for _, rr := range r.Ns {
+				if rr.Header().Class == dns.ClassNONE {
+					records = removeTestRecord(records, rr)
+				} else {
+					records = append(records, rr)
+				}
+			}
+		}
+		if r.IsTsig() != nil {
+			m.SetTsig(r.IsTsig().Hdr.Name, dns.HmacSHA256, 300, int64(r.IsTsig().TimeSigned))
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	server := &dns.Server{PacketConn: conn, Handler: handler, TsigSecret: map[string]string{"myapp-key.": testTsigSecret},
+		// the default accept function refuses the updates
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction { return dns.MsgAccept }}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+	return conn.LocalAddr().String()
+}
+
+func removeTestRecord(records []dns.RR, removed dns.RR) []dns.RR {
+	var kept []dns.RR
+	for _, rr := range records {
+		candidate := dns.Copy(removed)
+		candidate.Header().Class = dns.ClassINET
+		candidate.Header().Ttl = rr.Header().Ttl
+		if !dns.IsDuplicate(rr, candidate) {
+			kept = append(kept, rr)
+		}
+	}
+	return kept
+}
+
+func TestRFC2136Provider(t *testing.T) {
+	nameserver := startTestUpdateServer(t, "website.com.")
+	provider := &RFC2136Provider{Nameserver: nameserver, TSIGKeyName: "myapp-key", TSIGSecret: testTsigSecret}
+	txt := &DnsRecordInstruction{Method: MethodTxtRecord, HostName: "_myapp-challenge", Record: "myapp-site-verification=1234"}
+	cname := &DnsRecordInstruction{Method: MethodCnameRecord, HostName: "abcd", Record: "verify.myapp.com"}
+
+	for _, instruction := range []*DnsRecordInstruction{txt, cname} {
+		if err := provider.Present("website.com", instruction); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if got, err := CheckTxtRecord(nameserver, "website.com", txt.HostName, txt.Record); !got || err != nil {
+		t.Errorf("expected: %v, got: %v (%v)", true, got, err)
+	}
+	if got, err := CheckCnameRecord(nameserver, "website.com", cname.HostName, cname.Record); !got || err != nil {
+		t.Errorf("expected: %v, got: %v (%v)", true, got, err)
+	}
+
+	if err := provider.CleanUp("website.com", txt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := CheckTxtRecord(nameserver, "website.com", txt.HostName, txt.Record); got {
+		t.Errorf("expected: %v, got: %v", false, got)
+	}
+	if got, _ := CheckCnameRecord(nameserver, "website.com", cname.HostName, cname.Record); !got {
+		t.Errorf("expected the CNAME record to be kept")
+	}
+}
+
+func TestRFC2136ProviderErrors(t *testing.T) {
+	nameserver := startTestUpdateServer(t, "website.com.")
+	instruction := &DnsRecordInstruction{Method: MethodTxtRecord, HostName: "@", Record: "myapp=1234"}
+
+	tests := []struct {
+		name     string
+		provider *RFC2136Provider
+		wantErr  error
+	}{
+		{"unsigned update", &RFC2136Provider{Nameserver: nameserver}, DnsUpdateError},
+		{"wrong secret", &RFC2136Provider{Nameserver: nameserver, TSIGKeyName: "myapp-key", TSIGSecret: "b3RoZXI="}, nil},
+		{"wrong zone", &RFC2136Provider{Nameserver: nameserver, Zone: "other.com", TSIGKeyName: "myapp-key",
+			TSIGSecret: testTsigSecret}, DnsUpdateError},
+		{"no name server", &RFC2136Provider{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.provider.Present("website.com", instruction)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package domainverifier
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// RenderFormat is an output format of the instructions.
+type RenderFormat string
+
+const (
+	RenderText     RenderFormat = "text"
+	RenderMarkdown RenderFormat = "markdown"
+	RenderHtml     RenderFormat = "html"
+)
+
+// InvalidRenderFormatError indicates that the output format of the instructions is not supported.
+var InvalidRenderFormatError = errors.New("unsupported render format, expected text, markdown or html")
+
+// Step is a step of an instruction, built from the structured fields of the instruction.
+type Step struct {
+	Key      MessageKey // key of the sentence in the catalogs, used to translate Text
+	Text     string     // sentence of the step in DefaultLanguage, see Catalog for the placeholders of Values
+	Values   []string   // copy-ready values quoted in the sentence (e.g. a file name or a host name)
+	Code     string     // copy-ready block (e.g. a meta tag or a file content), empty if none
+	Language string     // language of Code (html, json or xml), empty for plain text
+	Note     bool       // the step is a reminder rather than an action
+}
+
+// Instruction is implemented by the instructions of every verification method,
+// so that they can be rendered with Render.
+type Instruction interface {
+	Steps() []Step
+}
+
+// Steps returns the steps of the HTML meta tag instruction.
+func (i *HtmlMetaInstruction) Steps() []Step {
+	if i == nil {
+		return nil
+	}
+	return metaTagSteps(i.Code, strings.TrimSpace(i.Path))
+}
+
+// metaTagSteps returns the steps adding the meta tag to a page, or to the home page when pagePath is empty.
+func metaTagSteps(tag, pagePath string) []Step {
+	first := newStep(MessageMetaTagHomePage, "<meta>")
+	if pagePath != "" {
+		first = newStep(MessageMetaTagPage, "<meta>", pagePath)
+	}
+	head := newStep(MessageMetaTagHead, "<head>", "<body>")
+	head.Code, head.Language = tag, "html"
+	keep := newStep(MessageMetaTagKeep)
+	keep.Note = true
+
+	return []Step{first, head, keep}
+}
+
+// Steps returns the steps of the JSON, XML or plain-text file instruction.
+func (i *FileInstruction) Steps() []Step {
+	if i == nil {
+		return nil
+	}
+
+	fileName := i.FileName
+	switch i.Method {
+	case MethodJson:
+		fileName = ensureFileExtension(fileName, ".json")
+	case MethodXml:
+		fileName = ensureFileExtension(fileName, ".xml")
+	}
+	return fileSteps(i.Method, fileName, i.FileContent, i.Path, i.Path == "/"+fileName)
+}
+
+// fileSteps returns the steps creating the file and uploading it to the root of the site,
+// or to filePath when root is false.
+func fileSteps(method Method, fileName, content, filePath string, root bool) []Step {
+	var create Step
+	switch method {
+	case MethodJson:
+		create = newStep(MessageJsonFileCreate, fileName)
+		create.Language = "json"
+	case MethodXml:
+		create = newStep(MessageXmlFileCreate, fileName)
+		create.Language = "xml"
+	default:
+		create = newStep(MessageTextFileCreate, fileName)
+	}
+	create.Code = content
+
+	upload := newStep(MessageFileUploadPath, filePath)
+	if root {
+		upload = newStep(MessageFileUploadRoot)
+	}
+
+	return []Step{create, upload}
+}
+
+// Steps returns the steps of the HTTP response header instruction.
+func (i *HttpHeaderInstruction) Steps() []Step {
+	if i == nil {
+		return nil
+	}
+	return httpHeaderSteps(fmt.Sprintf("%s: %s", i.HeaderName, i.HeaderValue))
+}
+
+// httpHeaderSteps returns the steps adding the header line to the responses of the site.
+func httpHeaderSteps(header string) []Step {
+	add := newStep(MessageHttpHeaderAdd)
+	add.Code = header
+	keep := newStep(MessageHttpHeaderKeep)
+	keep.Note = true
+	return []Step{add, keep}
+}
+
+// Steps returns the steps of the TXT or CNAME record instruction.
+func (i *DnsRecordInstruction) Steps() []Step {
+	if i == nil {
+		return nil
+	}
+	return dnsRecordSteps(i.Method, i.HostName, i.Record)
+}
+
+// dnsRecordSteps returns the steps creating the TXT or CNAME record.
+func dnsRecordSteps(method Method, hostName, record string) []Step {
+	create := newStep(MessageTxtRecordCreate, "TXT", hostName)
+	if method == MethodCnameRecord {
+		create = newStep(MessageCnameRecordCreate, "CNAME", hostName)
+	}
+	create.Code = record
+	return []Step{create}
+}
+
+// newStep returns a step holding the sentence of the key in DefaultLanguage.
+func newStep(key MessageKey, values ...string) Step {
+	return Step{Key: key, Text: Translate(key, DefaultLanguage), Values: values}
+}
+
+// Render renders the steps of an instruction as plain text, Markdown or an HTML fragment.
+// The actions are numbered and followed by the notes. Copy-ready blocks are rendered as
+// indented text, fenced code blocks or <pre><code> elements, and copy-ready values as inline code.
+// The Markdown and HTML outputs are escaped.
+//
+// Example:
+//
+//	instruction, _ := domainverifier.GenerateJson("myapp")
+//	markdown, err := domainverifier.Render(instruction, domainverifier.RenderMarkdown)
+func Render(instruction Instruction, format RenderFormat) (string, error) {
+	return RenderLocalized(instruction, format)
+}
+
+// RenderLocalized renders an instruction like Render, in the first of the languages
+// having a translation of each sentence (see Translate for the fallback chain).
+//
+// Example:
+//
+//	html, err := domainverifier.RenderLocalized(instruction, domainverifier.RenderHtml, "fr-CA", "de")
+func RenderLocalized(instruction Instruction, format RenderFormat, languages ...string) (string, error) {
+	if instruction == nil {
+		return "", errors.New("instruction cannot be nil")
+	}
+
+	var renderStep func(sb *strings.Builder, number int, step Step)
+	var renderNote func(sb *strings.Builder, step Step)
+	var start, end string
+
+	switch format {
+	case RenderText:
+		renderStep = renderTextStep
+		renderNote = func(sb *strings.Builder, step Step) {
+			sb.WriteString(fmt.Sprintf("* %s\n", fillValues(step, noEscape, noEscape)))
+		}
+	case RenderMarkdown:
+		renderStep = renderMarkdownStep
+		renderNote = func(sb *strings.Builder, step Step) {
+			sb.WriteString(fmt.Sprintf("> %s\n", fillValues(step, escapeMarkdown, markdownInlineCode)))
+		}
+	case RenderHtml:
+		start, end = "<ol>\n", "</ol>\n"
+		renderStep = renderHtmlStep
+		renderNote = func(sb *strings.Builder, step Step) {
+			sb.WriteString(fmt.Sprintf("<p class=\"note\">%s</p>\n", fillValues(step, html.EscapeString, htmlInlineCode)))
+		}
+	default:
+		return "", InvalidRenderFormatError
+	}
+
+	var actions, notes []Step
+	for _, step := range translateSteps(instruction.Steps(), languages...) {
+		if step.Note {
+			notes = append(notes, step)
+		} else {
+			actions = append(actions, step)
+		}
+	}
+
+	var sb strings.Builder
+	if len(actions) > 0 {
+		sb.WriteString(start)
+		for i, step := range actions {
+			renderStep(&sb, i+1, step)
+		}
+		sb.WriteString(end)
+	}
+	if len(notes) > 0 && len(actions) > 0 && format != RenderHtml {
+		sb.WriteString("\n")
+	}
+	for _, step := range notes {
+		renderNote(&sb, step)
+	}
+	return sb.String(), nil
+}
+
+// translateSteps returns the steps with their sentences in the first of the languages having a translation.
+func translateSteps(steps []Step, languages ...string) []Step {
+	translated := make([]Step, len(steps))
+	for i, step := range steps {
+		if step.Key != "" {
+			if text := Translate(step.Key, languages...); text != "" {
+				step.Text = text
+			}
+		}
+		translated[i] = step
+	}
+	return translated
+}
+
+// renderAction renders steps as the Action of an instruction: each sentence on its own line followed by
+// its copy-ready block, then the notes prefixed with "* ". The sentences are escaped with escapeText.
+func renderAction(steps []Step, escapeText func(string) string) string {
+	var lines, notes []string
+	for _, step := range steps {
+		if step.Note {
+			notes = append(notes, "* "+fillValues(step, escapeText, noEscape))
+			continue
+		}
+		lines = append(lines, fillValues(step, escapeText, noEscape))
+		if step.Code != "" {
+			lines = append(lines, step.Code)
+		}
+	}
+	return strings.Join(append(lines, notes...), "\n")
+}
+
+func renderTextStep(sb *strings.Builder, number int, step Step) {
+	sb.WriteString(fmt.Sprintf("%d. %s\n", number, fillValues(step, noEscape, noEscape)))
+	if step.Code != "" {
+		sb.WriteString(indentLines(step.Code, "   "))
+	}
+}
+
+func renderMarkdownStep(sb *strings.Builder, number int, step Step) {
+	sb.WriteString(fmt.Sprintf("%d. %s\n", number, fillValues(step, escapeMarkdown, markdownInlineCode)))
+	if step.Code != "" {
+		fence := strings.Repeat("`", maxInt(3, longestRun(step.Code, '`')+1))
+		sb.WriteString("\n")
+		sb.WriteString(indentLines(fmt.Sprintf("%s%s\n%s\n%s", fence, step.Language, step.Code, fence), "   "))
+	}
+}
+
+func renderHtmlStep(sb *strings.Builder, _ int, step Step) {
+	sb.WriteString(fmt.Sprintf("<li><p>%s</p>", fillValues(step, html.EscapeString, htmlInlineCode)))
+	if step.Code != "" {
+		class := ""
+		if step.Language != "" {
+			class = fmt.Sprintf(` class="language-%s"`, html.EscapeString(step.Language))
+		}
+		sb.WriteString(fmt.Sprintf("\n<pre><code%s>%s</code></pre>", class, html.EscapeString(step.Code)))
+	}
+	sb.WriteString("</li>\n")
+}
+
+// fillValues replaces the placeholders of the text of a step by its values.
+// The text is escaped with escapeText and each value is quoted with quoteValue.
+func fillValues(step Step, escapeText, quoteValue func(string) string) string {
+	var sb strings.Builder
+	scanPlaceholders(step.Text, func(segment string) {
+		sb.WriteString(escapeText(segment))
+	}, func(index int) {
+		if index < len(step.Values) {
+			sb.WriteString(quoteValue(step.Values[index]))
+		}
+	})
+	return sb.String()
+}
+
+// placeholderCount returns the number of values referenced by a sentence.
+func placeholderCount(text string) int {
+	count := 0
+	scanPlaceholders(text, func(string) {}, func(index int) {
+		count = maxInt(count, index+1)
+	})
+	return count
+}
+
+// scanPlaceholders splits a sentence into text segments and placeholders: %s references the value
+// following the previous one and %[n]s the n-th value (starting at 1), like fmt does.
+// placeholder is called with the zero-based index of the value.
+func scanPlaceholders(text string, segment func(string), placeholder func(index int)) {
+	next := 0
+	for {
+		start := strings.IndexByte(text, '%')
+		if start < 0 {
+			segment(text)
+			return
+		}
+
+		rest := text[start+1:]
+		index, length := -1, 0
+		if strings.HasPrefix(rest, "s") {
+			index, length = next, 1
+		} else if strings.HasPrefix(rest, "[") {
+			if end := strings.Index(rest, "]s"); end > 1 {
+				if n, err := strconv.Atoi(rest[1:end]); err == nil && n > 0 {
+					index, length = n-1, end+2
+				}
+			}
+		}
+
+		if index < 0 {
+			segment(text[:start+1])
+			text = rest
+			continue
+		}
+		segment(text[:start])
+		placeholder(index)
+		next = index + 1
+		text = rest[length:]
+	}
+}
+
+func noEscape(s string) string {
+	return s
+}
+
+// escapeMarkdown escapes the characters having a meaning in Markdown inline text.
+func escapeMarkdown(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_[]<>#|", r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// markdownInlineCode wraps a value in a code span delimited by more backticks than the value contains.
+func markdownInlineCode(s string) string {
+	delimiter := strings.Repeat("`", longestRun(s, '`')+1)
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return delimiter + s + delimiter
+}
+
+func htmlInlineCode(s string) string {
+	return fmt.Sprintf("<code>%s</code>", html.EscapeString(s))
+}
+
+// indentLines prefixes every line of s with indent and ends it with a new line.
+func indentLines(s, indent string) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(s, "\n") {
+		sb.WriteString(indent)
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// longestRun returns the length of the longest run of r in s.
+func longestRun(s string, r rune) int {
+	longest, current := 0, 0
+	for _, c := range s {
+		if c == r {
+			current++
+			longest = maxInt(longest, current)
+		} else {
+			current = 0
+		}
+	}
+	return longest
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,96 @@
+package domainverifier
+
+import (
+	"github.com/egbakou/domainverifier/config"
+	"html"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	metaInstruction, _ := GenerateHtmlMetaFromConfig(&config.HmlMetaTagGenerator{TagName: "myapp", Code: "1234"}, false)
+	jsonInstruction, _ := GenerateJsonFromConfig(&config.JsonGenerator{
+		FileName: "myapp", Attribute: "myapp_site_verification", Code: "1234", Path: "/.well-known"}, false)
+	txtInstruction, _ := GenerateTxtRecordFromConfig(&config.TxtRecordGenerator{
+		HostName: "_myapp-challenge", RecordAttribute: "myapp", RecordAttributeValue: "1234"}, false)
+
+	type args struct {
+		instruction Instruction
+		format      RenderFormat
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr error
+	}{
+		{
+			name: "meta tag as text",
+			args: args{metaInstruction, RenderText},
+			want: "1. Copy and paste the <meta> tag into your site's home page.\n" +
+				"2. It should go in the <head> section, before the first <body> section.\n" +
+				"   <meta name=\"myapp\" content=\"1234\" />\n" +
+				"\n" +
+				"* To stay verified, don't remove the meta tag even after verification succeeds.\n",
+		},
+		{
+			name: "meta tag as markdown",
+			args: args{metaInstruction, RenderMarkdown},
+			want: "1. Copy and paste the `<meta>` tag into your site's home page.\n" +
+				"2. It should go in the `<head>` section, before the first `<body>` section.\n" +
+				"\n" +
+				"   ```html\n" +
+				"   <meta name=\"myapp\" content=\"1234\" />\n" +
+				"   ```\n" +
+				"\n" +
+				"> To stay verified, don't remove the meta tag even after verification succeeds.\n",
+		},
+		{
+			name: "json file as html",
+			args: args{jsonInstruction, RenderHtml},
+			want: "<ol>\n" +
+				"<li><p>Create a JSON file named <code>myapp.json</code> with the content:</p>\n" +
+				"<pre><code class=\"language-json\">{&#34;myapp_site_verification&#34;: &#34;1234&#34;}</code></pre></li>\n" +
+				"<li><p>Upload it to your site so that it is served at <code>/.well-known/myapp.json</code>.</p></li>\n" +
+				"</ol>\n",
+		},
+		{
+			name: "txt record as markdown",
+			args: args{txtInstruction, RenderMarkdown},
+			want: "1. Create a `TXT` record with the name `_myapp-challenge` and the following content:\n" +
+				"\n" +
+				"   ```\n" +
+				"   myapp=1234\n" +
+				"   ```\n",
+		},
+		{
+			name:    "unsupported format",
+			args:    args{txtInstruction, RenderFormat("pdf")},
+			wantErr: InvalidRenderFormatError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.args.instruction, tt.args.format)
+			if err != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Render() got =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderEscaping(t *testing.T) {
+	step := Step{Text: "Name the file %s [now]", Values: []string{"a`b<c>.txt"}}
+
+	if got := fillValues(step, escapeMarkdown, markdownInlineCode); got != "Name the file ``a`b<c>.txt`` \\[now\\]" {
+		t.Errorf("expected escaped markdown, got: %v", got)
+	}
+	if got := fillValues(step, html.EscapeString, htmlInlineCode); got != "Name the file <code>a`b&lt;c&gt;.txt</code> [now]" {
+		t.Errorf("expected escaped html, got: %v", got)
+	}
+	if got := markdownInlineCode("`code`"); got != "`` `code` ``" {
+		t.Errorf("expected: %v, got: %v", "`` `code` ``", got)
+	}
+}
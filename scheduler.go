@@ -0,0 +1,207 @@
+package domainverifier
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRecheckInterval is the interval between two re-checks of a verified challenge, when none is configured.
+	DefaultRecheckInterval = 24 * time.Hour
+	// DefaultMaxRecheckFailures is the number of consecutive failed re-checks after which the verification is lost,
+	// when none is configured.
+	DefaultMaxRecheckFailures = 3
+
+	// maxSchedulerPoll is the longest time the scheduler waits before looking for due challenges.
+	maxSchedulerPoll = time.Minute
+)
+
+// SchedulerOptions configures a Scheduler.
+type SchedulerOptions struct {
+	Interval time.Duration // interval between two re-checks of a challenge, DefaultRecheckInterval when 0
+	// Jitter is the maximum random delay added to the interval, so that the challenges verified at the same time
+	// are not re-checked at the same time. No delay is added when 0.
+	Jitter time.Duration
+	// MaxFailures is the number of consecutive failed re-checks tolerated before the verification is lost
+	// (the grace period), DefaultMaxRecheckFailures when 0.
+	MaxFailures int
+	OnFailure   func(challenge *Challenge, err error) // optional, called after a failed re-check within the grace period
+	OnLost      func(challenge *Challenge)            // optional, called when the verification is lost
+}
+
+// Scheduler periodically re-checks the verified challenges of a LifecycleManager, to detect that a domain
+// expired or changed hands, e.g. because the meta tag or the TXT record was removed.
+// A challenge is re-checked every Interval, plus a random jitter, after its last re-check or its verification.
+type Scheduler struct {
+	manager  *LifecycleManager
+	options  SchedulerOptions
+	mu       sync.Mutex // guards next, checking and rand, never held during a re-check
+	next     map[string]time.Time
+	checking map[string]bool // challenges being re-checked by a run
+	rand     *rand.Rand
+}
+
+// NewScheduler returns a scheduler re-checking the verified challenges of the manager. options can be nil.
+//
+// Example:
+//
+//	scheduler := domainverifier.NewScheduler(manager, &domainverifier.SchedulerOptions{
+//		Interval:    12 * time.Hour,
+//		Jitter:      time.Hour,
+//		MaxFailures: 3,
+//		OnLost: func(challenge *domainverifier.Challenge) {
+//			revokeAccess(challenge.Account, challenge.Domain)
+//		},
+//	})
+//	go scheduler.Run(ctx)
+func NewScheduler(manager *LifecycleManager, options *SchedulerOptions) *Scheduler {
+	s := &Scheduler{
+		manager:  manager,
+		next:     map[string]time.Time{},
+		checking: map[string]bool{},
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if options != nil {
+		s.options = *options
+	}
+	if s.options.Interval <= 0 {
+		s.options.Interval = DefaultRecheckInterval
+	}
+	if s.options.MaxFailures <= 0 {
+		s.options.MaxFailures = DefaultMaxRecheckFailures
+	}
+	return s
+}
+
+// Run re-checks the due challenges until the context is done, and returns the context error.
+// The context also cancels the re-check in progress.
+// The errors of the store are not fatal: the challenges are tried again on the next run.
+func (s *Scheduler) Run(ctx context.Context) error {
+	poll := s.options.Interval
+	if poll > maxSchedulerPoll {
+		poll = maxSchedulerPoll
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for {
+		_ = s.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce re-checks the verified challenges that are due, and returns the first error of the store.
+// It stops, returning the context error, when the context is done. A challenge being re-checked
+// by a concurrent run is skipped.
+func (s *Scheduler) RunOnce(ctx context.Context) error {
+	due, err := s.due()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		s.mu.Lock()
+		for _, id := range due {
+			delete(s.checking, id)
+		}
+		s.mu.Unlock()
+	}()
+
+	var firstErr error
+	for _, id := range due {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// the re-check is done without holding the lock, so that it does not block the other runs
+		now := s.manager.now()
+		challenge, err := s.manager.Recheck(ctx, id, s.options.MaxFailures)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		s.mu.Lock()
+		switch {
+		case errors.Is(err, ChallengeNotVerifiedError) || errors.Is(err, ChallengeNotFoundError):
+			// the challenge changed since it was listed
+			delete(s.next, id)
+		case err != nil:
+		case challenge.Status == ChallengeLost:
+			delete(s.next, id)
+		default:
+			s.next[id] = s.nextCheck(now)
+		}
+		s.mu.Unlock()
+
+		switch {
+		case errors.Is(err, ChallengeNotVerifiedError) || errors.Is(err, ChallengeNotFoundError):
+		case err != nil:
+			if firstErr == nil {
+				firstErr = err
+			}
+		case challenge.Status == ChallengeLost:
+			if s.options.OnLost != nil {
+				s.options.OnLost(challenge)
+			}
+		case challenge.RecheckFailures > 0 && s.options.OnFailure != nil:
+			s.options.OnFailure(challenge, errors.New(challenge.LastError))
+		}
+	}
+	return firstErr
+}
+
+// due returns the IDs of the verified challenges whose re-check is due and not already in progress,
+// and marks them as being re-checked.
+func (s *Scheduler) due() ([]string, error) {
+	challenges, err := s.manager.store.ListByStatus(ChallengeVerified)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.manager.now()
+	verified := make(map[string]bool, len(challenges))
+	var due []string
+	for _, challenge := range challenges {
+		verified[challenge.ID] = true
+
+		next, scheduled := s.next[challenge.ID]
+		if !scheduled {
+			last := challenge.CheckedAt
+			if last.IsZero() {
+				last = challenge.UpdatedAt
+			}
+			next = s.nextCheck(last)
+			s.next[challenge.ID] = next
+		}
+		if now.Before(next) || s.checking[challenge.ID] {
+			continue
+		}
+		s.checking[challenge.ID] = true
+		due = append(due, challenge.ID)
+	}
+
+	// forget the challenges that are no longer verified
+	for id := range s.next {
+		if !verified[id] {
+			delete(s.next, id)
+		}
+	}
+	return due, nil
+}
+
+// nextCheck returns the time of the re-check following a check at last.
+func (s *Scheduler) nextCheck(last time.Time) time.Time {
+	next := last.Add(s.options.Interval)
+	if s.options.Jitter > 0 {
+		next = next.Add(time.Duration(s.rand.Int63n(int64(s.options.Jitter) + 1)))
+	}
+	return next
+}
@@ -0,0 +1,141 @@
+package domainverifier
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRunOnce(t *testing.T) {
+	manager, events, now := newTestLifecycle(t, &LifecycleOptions{})
+	start := *now
+	keptID := createTestChallenge(t, manager, "website.com", start)
+	lostID := createTestChallenge(t, manager, "other.com", start)
+	pendingID := createTestChallenge(t, manager, "website.com", start)
+	for _, id := range []string{keptID, lostID} {
+		if _, err := manager.Transition(id, ChallengeVerified, ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var failures, lost []string
+	scheduler := NewScheduler(manager, &SchedulerOptions{
+		Interval:    time.Hour,
+		MaxFailures: 2,
+		OnFailure:   func(c *Challenge, err error) { failures = append(failures, c.ID) },
+		OnLost:      func(c *Challenge) { lost = append(lost, c.ID) },
+	})
+
+	steps := []struct {
+		elapsed      time.Duration
+		wantFailures int
+		wantLost     int
+	}{
+		{30 * time.Minute, 0, 0},
+		{time.Hour, 1, 0},
+		{90 * time.Minute, 1, 0},
+		{2 * time.Hour, 1, 1},
+		{4 * time.Hour, 1, 1},
+	}
+	for _, step := range steps {
+		*now = start.Add(step.elapsed)
+		if err := scheduler.RunOnce(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(failures) != step.wantFailures || len(lost) != step.wantLost {
+			t.Errorf("after %v, expected: %v failures and %v lost, got: %v and %v",
+				step.elapsed, step.wantFailures, step.wantLost, len(failures), len(lost))
+		}
+	}
+
+	kept, _ := manager.store.Get(keptID)
+	if kept.Status != ChallengeVerified || kept.RecheckFailures != 0 || !kept.CheckedAt.Equal(start.Add(4*time.Hour)) {
+		t.Errorf("expected a verified challenge re-checked at %v, got: %+v", start.Add(4*time.Hour), kept)
+	}
+	lostChallenge, _ := manager.store.Get(lostID)
+	if lostChallenge.Status != ChallengeLost || lostChallenge.RecheckFailures != 2 || lostChallenge.LastError == "" {
+		t.Errorf("expected a lost challenge, got: %+v", lostChallenge)
+	}
+	if pending, _ := manager.store.Get(pendingID); pending.CheckedAt != (time.Time{}) {
+		t.Errorf("expected the pending challenge not to be re-checked")
+	}
+	if last := (*events)[len(*events)-1]; last.Challenge.ID != lostID || last.Transition.To != ChallengeLost {
+		t.Errorf("expected a lost event, got: %+v", last)
+	}
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	manager, _, _ := newTestLifecycle(t, &LifecycleOptions{})
+	scheduler := NewScheduler(manager, &SchedulerOptions{Interval: time.Hour, Jitter: 10 * time.Minute})
+	last := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		next := scheduler.nextCheck(last)
+		if next.Before(last.Add(time.Hour)) || next.After(last.Add(70*time.Minute)) {
+			t.Fatalf("expected a re-check between 1h and 1h10m after the last one, got: %v", next.Sub(last))
+		}
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	manager, _, _ := newTestLifecycle(t, &LifecycleOptions{})
+	scheduler := NewScheduler(manager, &SchedulerOptions{Interval: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := scheduler.Run(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected: %v, got: %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestLifecycleManagerRecheck(t *testing.T) {
+	manager, _, now := newTestLifecycle(t, &LifecycleOptions{})
+	id := createTestChallenge(t, manager, "other.com", *now)
+
+	if _, err := manager.Recheck(context.Background(), id, 1); err != ChallengeNotVerifiedError {
+		t.Errorf("expected: %v, got: %v", ChallengeNotVerifiedError, err)
+	}
+	if _, err := manager.Transition(id, ChallengeVerified, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	challenge, err := manager.Recheck(context.Background(), id, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if challenge.Status != ChallengeLost {
+		t.Errorf("expected: %v, got: %v", ChallengeLost, challenge.Status)
+	}
+	if _, err := manager.Transition(id, ChallengeVerified, ""); err != InvalidTransitionError {
+		t.Errorf("expected: %v, got: %v", InvalidTransitionError, err)
+	}
+}
+
+func TestSchedulerRunOnceCancelled(t *testing.T) {
+	manager, _, now := newTestLifecycle(t, &LifecycleOptions{})
+	start := *now
+	id := createTestChallenge(t, manager, "other.com", start)
+	if _, err := manager.Transition(id, ChallengeVerified, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	scheduler := NewScheduler(manager, &SchedulerOptions{Interval: time.Hour, MaxFailures: 1})
+	*now = start.Add(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := scheduler.RunOnce(ctx); err != context.Canceled {
+		t.Errorf("expected: %v, got: %v", context.Canceled, err)
+	}
+	if challenge, _ := manager.store.Get(id); challenge.Status != ChallengeVerified || !challenge.CheckedAt.IsZero() {
+		t.Errorf("expected the challenge not to be re-checked, got: %+v", challenge)
+	}
+	if len(scheduler.checking) != 0 {
+		t.Errorf("expected no challenge being re-checked, got: %v", scheduler.checking)
+	}
+
+	// the cancelled run does not prevent the next one
+	if err := scheduler.RunOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if challenge, _ := manager.store.Get(id); challenge.Status != ChallengeLost {
+		t.Errorf("expected: %v, got: %v", ChallengeLost, challenge.Status)
+	}
+}
@@ -0,0 +1,336 @@
+package domainverifier
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/segmentio/ksuid"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ChallengeNotFoundError indicates that the store has no challenge with the requested ID.
+	ChallengeNotFoundError = errors.New("challenge not found")
+
+	// ChallengeExistsError indicates that the store already has a challenge with the same ID.
+	ChallengeExistsError = errors.New("a challenge with the same ID already exists")
+)
+
+// ChallengeStore persists the issued challenges. The implementations are safe for concurrent use
+// and return copies of the stored challenges.
+type ChallengeStore interface {
+	// Create records a new challenge. A ksuid is assigned when its ID is empty,
+	// the pending status when its status is empty, and the creation time when it is zero.
+	Create(challenge *Challenge) error
+	// Get returns the challenge with the ID, or ChallengeNotFoundError.
+	Get(id string) (*Challenge, error)
+	// FindByDomain returns the challenges of the domain, for the account when it is not empty, oldest first.
+	FindByDomain(domain, account string) ([]*Challenge, error)
+	// ListPending returns the pending challenges, oldest first.
+	ListPending() ([]*Challenge, error)
+	// ListByStatus returns the challenges with the status, oldest first.
+	ListByStatus(status ChallengeStatus) ([]*Challenge, error)
+	// Update replaces the stored challenge with the same ID, or returns ChallengeNotFoundError.
+	// The status is stored as is, see UpdateStatus.
+	Update(challenge *Challenge) error
+	// UpdateStatus sets the status of the challenge with the ID and records the change in its history,
+	// or returns ChallengeNotFoundError. Any status is accepted, the transition is not checked.
+	UpdateStatus(id string, status ChallengeStatus) error
+	// Delete removes the challenge with the ID, or returns ChallengeNotFoundError.
+	Delete(id string) error
+}
+
+// MemoryChallengeStore is a ChallengeStore keeping the challenges in memory.
+type MemoryChallengeStore struct {
+	mu         sync.RWMutex
+	challenges map[string]*Challenge
+}
+
+// NewMemoryChallengeStore returns an empty in-memory store.
+func NewMemoryChallengeStore() *MemoryChallengeStore {
+	return &MemoryChallengeStore{challenges: map[string]*Challenge{}}
+}
+
+func (s *MemoryChallengeStore) Create(challenge *Challenge) error {
+	if challenge == nil {
+		return InvalidChallengeError
+	}
+	// the defaults are applied to a copy, so that the challenge is left untouched when it is rejected
+	c := copyChallenge(challenge)
+	if c.ID == "" {
+		c.ID = ksuid.New().String()
+	}
+	if c.Status == "" {
+		c.Status = ChallengePending
+	}
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now().UTC()
+	}
+	if c.UpdatedAt.IsZero() {
+		c.UpdatedAt = c.CreatedAt
+	}
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.challenges[c.ID]; exists {
+		return ChallengeExistsError
+	}
+	s.challenges[c.ID] = c
+	challenge.ID, challenge.Status, challenge.CreatedAt, challenge.UpdatedAt = c.ID, c.Status, c.CreatedAt, c.UpdatedAt
+	return nil
+}
+
+func (s *MemoryChallengeStore) Get(id string) (*Challenge, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	challenge, exists := s.challenges[id]
+	if !exists {
+		return nil, ChallengeNotFoundError
+	}
+	return copyChallenge(challenge), nil
+}
+
+func (s *MemoryChallengeStore) FindByDomain(domain, account string) ([]*Challenge, error) {
+	domain = strings.TrimSuffix(domain, ".")
+	return s.filter(func(c *Challenge) bool {
+		return strings.EqualFold(strings.TrimSuffix(c.Domain, "."), domain) && (account == "" || c.Account == account)
+	}), nil
+}
+
+func (s *MemoryChallengeStore) ListPending() ([]*Challenge, error) {
+	return s.ListByStatus(ChallengePending)
+}
+
+func (s *MemoryChallengeStore) ListByStatus(status ChallengeStatus) ([]*Challenge, error) {
+	return s.filter(func(c *Challenge) bool {
+		return c.Status == status
+	}), nil
+}
+
+func (s *MemoryChallengeStore) Update(challenge *Challenge) error {
+	if err := challenge.validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.challenges[challenge.ID]; !exists {
+		return ChallengeNotFoundError
+	}
+	s.challenges[challenge.ID] = copyChallenge(challenge)
+	return nil
+}
+
+func (s *MemoryChallengeStore) UpdateStatus(id string, status ChallengeStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	challenge, exists := s.challenges[id]
+	if !exists {
+		return ChallengeNotFoundError
+	}
+	// the stored challenges are never modified in place, see snapshot
+	c := copyChallenge(challenge)
+	now := time.Now().UTC()
+	c.History = append(c.History, Transition{From: c.Status, To: status, At: now})
+	c.Status, c.UpdatedAt = status, now
+	s.challenges[id] = c
+	return nil
+}
+
+func (s *MemoryChallengeStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.challenges[id]; !exists {
+		return ChallengeNotFoundError
+	}
+	delete(s.challenges, id)
+	return nil
+}
+
+// filter returns copies of the challenges matching the predicate, oldest first.
+func (s *MemoryChallengeStore) filter(match func(c *Challenge) bool) []*Challenge {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var challenges []*Challenge
+	for _, challenge := range s.challenges {
+		if match(challenge) {
+			challenges = append(challenges, copyChallenge(challenge))
+		}
+	}
+	sortChallenges(challenges)
+	return challenges
+}
+
+// snapshot returns the stored challenges, to be restored after a failed change.
+// The challenges are never modified in place, so the map is copied but not the challenges.
+func (s *MemoryChallengeStore) snapshot() map[string]*Challenge {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	challenges := make(map[string]*Challenge, len(s.challenges))
+	for id, challenge := range s.challenges {
+		challenges[id] = challenge
+	}
+	return challenges
+}
+
+func (s *MemoryChallengeStore) restore(challenges map[string]*Challenge) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.challenges = challenges
+}
+
+func sortChallenges(challenges []*Challenge) {
+	sort.Slice(challenges, func(i, j int) bool {
+		if !challenges[i].CreatedAt.Equal(challenges[j].CreatedAt) {
+			return challenges[i].CreatedAt.Before(challenges[j].CreatedAt)
+		}
+		return challenges[i].ID < challenges[j].ID
+	})
+}
+
+// copyChallenge returns a copy of the challenge. The method configs are shared: they are not modified once issued.
+func copyChallenge(challenge *Challenge) *Challenge {
+	c := *challenge
+	c.History = append([]Transition(nil), challenge.History...)
+	if challenge.Methods != nil {
+		methods := *challenge.Methods
+		c.Methods = &methods
+	}
+	return &c
+}
+
+// FileChallengeStore is a ChallengeStore keeping the challenges in memory and saving them to a JSON file
+// after each change. The file is replaced atomically, so that it is never left half-written.
+type FileChallengeStore struct {
+	mu     sync.Mutex // serializes the changes and the saves
+	path   string
+	memory *MemoryChallengeStore
+}
+
+// NewFileChallengeStore returns a store saving the challenges to the JSON file at path,
+// loading the challenges it already holds. The file is created on the first change when it does not exist.
+//
+// Example:
+//
+//	store, err := domainverifier.NewFileChallengeStore("/var/lib/myapp/challenges.json")
+//	issuer := domainverifier.NewChallengeIssuer(store)
+func NewFileChallengeStore(path string) (*FileChallengeStore, error) {
+	s := &FileChallengeStore{path: path, memory: NewMemoryChallengeStore()}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var challenges []*Challenge
+	if err := json.Unmarshal(data, &challenges); err != nil {
+		return nil, err
+	}
+	for _, challenge := range challenges {
+		if err := challenge.validate(); err != nil {
+			return nil, err
+		}
+		s.memory.challenges[challenge.ID] = challenge
+	}
+	return s, nil
+}
+
+func (s *FileChallengeStore) Create(challenge *Challenge) error {
+	if challenge == nil {
+		return InvalidChallengeError
+	}
+	// the defaults are reported to the caller only once the challenge is saved
+	c := copyChallenge(challenge)
+	if err := s.change(func() error {
+		return s.memory.Create(c)
+	}); err != nil {
+		return err
+	}
+	challenge.ID, challenge.Status, challenge.CreatedAt, challenge.UpdatedAt = c.ID, c.Status, c.CreatedAt, c.UpdatedAt
+	return nil
+}
+
+func (s *FileChallengeStore) Get(id string) (*Challenge, error) {
+	return s.memory.Get(id)
+}
+
+func (s *FileChallengeStore) FindByDomain(domain, account string) ([]*Challenge, error) {
+	return s.memory.FindByDomain(domain, account)
+}
+
+func (s *FileChallengeStore) ListPending() ([]*Challenge, error) {
+	return s.memory.ListPending()
+}
+
+func (s *FileChallengeStore) ListByStatus(status ChallengeStatus) ([]*Challenge, error) {
+	return s.memory.ListByStatus(status)
+}
+
+func (s *FileChallengeStore) Update(challenge *Challenge) error {
+	return s.change(func() error {
+		return s.memory.Update(challenge)
+	})
+}
+
+func (s *FileChallengeStore) UpdateStatus(id string, status ChallengeStatus) error {
+	return s.change(func() error {
+		return s.memory.UpdateStatus(id, status)
+	})
+}
+
+func (s *FileChallengeStore) Delete(id string) error {
+	return s.change(func() error {
+		return s.memory.Delete(id)
+	})
+}
+
+// change applies a change to the challenges in memory, then saves them.
+// The change is rolled back when the challenges cannot be saved, so that the memory matches the file.
+func (s *FileChallengeStore) change(apply func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := s.memory.snapshot()
+	if err := apply(); err != nil {
+		return err
+	}
+	if err := s.save(); err != nil {
+		s.memory.restore(snapshot)
+		return err
+	}
+	return nil
+}
+
+func (s *FileChallengeStore) save() error {
+	challenges := s.memory.filter(func(c *Challenge) bool { return true })
+	if challenges == nil {
+		challenges = []*Challenge{}
+	}
+	data, err := json.MarshalIndent(challenges, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,228 @@
+package domainverifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/egbakou/domainverifier/config"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestChallenge(domain, account string) *Challenge {
+	return &Challenge{
+		Domain:  domain,
+		Account: account,
+		Method:  MethodTxtRecord,
+		Methods: &config.Methods{TxtRecord: &config.TxtRecordGenerator{HostName: "@", RecordAttribute: "myapp", RecordAttributeValue: "1234"}},
+	}
+}
+
+func testChallengeStores(t *testing.T) map[string]func() ChallengeStore {
+	dir := t.TempDir()
+	return map[string]func() ChallengeStore{
+		"memory": func() ChallengeStore { return NewMemoryChallengeStore() },
+		"file": func() ChallengeStore {
+			store, err := NewFileChallengeStore(filepath.Join(dir, fmt.Sprintf("challenges-%d.json", time.Now().UnixNano())))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return store
+		},
+	}
+}
+
+func TestChallengeStore(t *testing.T) {
+	for name, newStore := range testChallengeStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+
+			first := newTestChallenge("website.com", "alice")
+			if err := store.Create(first); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if first.ID == "" || first.Status != ChallengePending || first.CreatedAt.IsZero() {
+				t.Errorf("expected an ID, the pending status and a creation time, got: %+v", first)
+			}
+			second := newTestChallenge("Website.com.", "bob")
+			second.CreatedAt = first.CreatedAt.Add(time.Second)
+			if err := store.Create(second); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := store.Create(newTestChallenge("other.com", "alice")); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if err := store.Create(&Challenge{ID: first.ID, Domain: "website.com", Methods: &config.Methods{}}); err != ChallengeExistsError {
+				t.Errorf("expected: %v, got: %v", ChallengeExistsError, err)
+			}
+			invalid := newTestChallenge("not a domain", "")
+			if err := store.Create(invalid); err != InvalidChallengeError {
+				t.Errorf("expected: %v, got: %v", InvalidChallengeError, err)
+			}
+			if invalid.ID != "" || invalid.Status != "" || !invalid.CreatedAt.IsZero() {
+				t.Errorf("expected the rejected challenge to be untouched, got: %+v", invalid)
+			}
+
+			got, err := store.Get(first.ID)
+			if err != nil || got.Domain != "website.com" || got.Methods.TxtRecord.RecordAttributeValue != "1234" {
+				t.Errorf("expected: %+v, got: %+v (%v)", first, got, err)
+			}
+			got.Status = ChallengeFailed
+			if stored, _ := store.Get(first.ID); stored.Status != ChallengePending {
+				t.Errorf("expected a copy of the stored challenge")
+			}
+
+			if found, _ := store.FindByDomain("website.com", ""); len(found) != 2 || found[0].ID != first.ID || found[1].ID != second.ID {
+				t.Errorf("expected the 2 challenges of website.com, oldest first, got: %v", found)
+			}
+			if found, _ := store.FindByDomain("website.com", "bob"); len(found) != 1 || found[0].ID != second.ID {
+				t.Errorf("expected the challenge of bob, got: %v", found)
+			}
+
+			if err := store.UpdateStatus(first.ID, ChallengeVerified); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if pending, _ := store.ListPending(); len(pending) != 2 {
+				t.Errorf("expected: %v, got: %v", 2, len(pending))
+			}
+			updated, _ := store.Get(first.ID)
+			if len(updated.History) != 1 || updated.History[0].From != ChallengePending || updated.History[0].To != ChallengeVerified {
+				t.Errorf("expected the status change in the history, got: %+v", updated.History)
+			}
+
+			if err := store.Delete(second.ID); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := store.Get(second.ID); err != ChallengeNotFoundError {
+				t.Errorf("expected: %v, got: %v", ChallengeNotFoundError, err)
+			}
+			if err := store.Update(second); err != ChallengeNotFoundError {
+				t.Errorf("expected: %v, got: %v", ChallengeNotFoundError, err)
+			}
+			if err := store.UpdateStatus(second.ID, ChallengeFailed); err != ChallengeNotFoundError {
+				t.Errorf("expected: %v, got: %v", ChallengeNotFoundError, err)
+			}
+			if err := store.Delete(second.ID); err != ChallengeNotFoundError {
+				t.Errorf("expected: %v, got: %v", ChallengeNotFoundError, err)
+			}
+		})
+	}
+}
+
+func TestChallengeStoreConcurrency(t *testing.T) {
+	for name, newStore := range testChallengeStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					challenge := newTestChallenge("website.com", "")
+					if err := store.Create(challenge); err != nil {
+						t.Errorf("unexpected error: %v", err)
+						return
+					}
+					_ = store.UpdateStatus(challenge.ID, ChallengeVerified)
+					_, _ = store.ListPending()
+				}()
+			}
+			wg.Wait()
+
+			if found, _ := store.FindByDomain("website.com", ""); len(found) != 20 {
+				t.Errorf("expected: %v, got: %v", 20, len(found))
+			}
+		})
+	}
+}
+
+func TestFileChallengeStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "challenges.json")
+	store, err := NewFileChallengeStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	challenge := newTestChallenge("website.com", "alice")
+	if err := store.Create(challenge); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.UpdateStatus(challenge.ID, ChallengeVerified); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := NewFileChallengeStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := reopened.Get(challenge.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Status != ChallengeVerified || got.Account != "alice" || got.Methods.TxtRecord.RecordAttributeValue != "1234" ||
+		!got.CreatedAt.Equal(challenge.CreatedAt) {
+		t.Errorf("expected: %+v, got: %+v", challenge, got)
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := NewFileChallengeStore(path); err == nil {
+		t.Errorf("expected an error for a corrupted file")
+	}
+	if _, err := NewFileChallengeStore(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("unexpected error for a missing file: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`[{"id":"1","domain":"website.com"}]`), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := NewFileChallengeStore(path); !errors.Is(err, InvalidChallengeError) {
+		t.Errorf("expected: %v, got: %v", InvalidChallengeError, err)
+	}
+}
+
+func TestFileChallengeStoreRollback(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "challenges")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store, err := NewFileChallengeStore(filepath.Join(dir, "challenges.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	saved := newTestChallenge("website.com", "alice")
+	if err := store.Create(saved); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the file cannot be saved once its directory is removed
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	unsaved := newTestChallenge("other.com", "alice")
+	if err := store.Create(unsaved); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if unsaved.ID != "" {
+		t.Errorf("expected the unsaved challenge to be untouched, got: %+v", unsaved)
+	}
+	if found, _ := store.FindByDomain("other.com", ""); len(found) != 0 {
+		t.Errorf("expected the creation to be rolled back, got: %v", found)
+	}
+
+	saved.Status = ChallengeVerified
+	if err := store.Update(saved); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if got, _ := store.Get(saved.ID); got.Status != ChallengePending {
+		t.Errorf("expected: %v, got: %v", ChallengePending, got.Status)
+	}
+	if err := store.Delete(saved.ID); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if _, err := store.Get(saved.ID); err != nil {
+		t.Errorf("expected the deletion to be rolled back, got: %v", err)
+	}
+}
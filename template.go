@@ -0,0 +1,91 @@
+package domainverifier
+
+import (
+	"github.com/egbakou/domainverifier/config"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+)
+
+// TemplateData holds the structured fields of an instruction, exposed to the action templates
+// of the generator configs (see config.ActionOptions).
+type TemplateData struct {
+	Method     Method
+	Domain     string   // the Domain of the config, empty when not set
+	Name       string   // meta tag name, JSON attribute, XML root element or header name
+	Code       string   // first verification code
+	Codes      []string // every verification code
+	Content    string   // meta tag, file content, header line or DNS record value
+	FileName   string   // name of the verification file
+	FilePath   string   // URL path of the verification file, or of the page holding the meta tag
+	HostName   string   // name of the DNS record
+	Record     string   // value of the DNS record
+	RecordType string   // TXT or CNAME
+	Default    string   // default action, to extend it rather than replace it
+}
+
+// DefaultActionTemplate returns the action template producing the default action of the method,
+// a starting point for a custom config.ActionOptions.ActionTemplate.
+// The template is built from the same steps and DefaultLanguage sentences as the default action,
+// so it follows the sentences overridden with RegisterCatalog.
+// It returns an empty string for an unknown method.
+func DefaultActionTemplate(method Method) string {
+	action := func(steps []Step) string {
+		return renderAction(translateSteps(steps, DefaultLanguage), escapeTemplateText)
+	}
+
+	switch method {
+	case MethodHtmlMeta:
+		return "{{if .FilePath}}" + action(metaTagSteps("{{.Content}}", "{{.FilePath}}")) +
+			"{{else}}" + action(metaTagSteps("{{.Content}}", "")) + "{{end}}"
+	case MethodJson, MethodXml, MethodTextFile:
+		return `{{if eq .FilePath (printf "/%s" .FileName)}}` +
+			action(fileSteps(method, "{{.FileName}}", "{{.Content}}", "{{.FilePath}}", true)) +
+			"{{else}}" + action(fileSteps(method, "{{.FileName}}", "{{.Content}}", "{{.FilePath}}", false)) + "{{end}}"
+	case MethodHttpHeader:
+		return action(httpHeaderSteps("{{.Content}}"))
+	case MethodTxtRecord, MethodCnameRecord:
+		return action(dnsRecordSteps(method, "{{.HostName}}", "{{.Record}}"))
+	}
+	return ""
+}
+
+// escapeTemplateText escapes the actions delimiters of a sentence, so that it is kept as is in a template.
+func escapeTemplateText(s string) string {
+	return strings.ReplaceAll(s, "{{", "{{`{{`}}")
+}
+
+// executeActionTemplate returns the action of an instruction: data.Default when options has no template,
+// otherwise the result of the template executed with data.
+// data.Default is the default action of the instruction, built from its steps in options.Language,
+// or in DefaultLanguage when not set (see Translate).
+func executeActionTemplate(options config.ActionOptions, data TemplateData, instruction Instruction) (string, error) {
+	if instruction != nil {
+		data.Default = renderAction(translateSteps(instruction.Steps(), options.Language), noEscape)
+	}
+	if strings.TrimSpace(options.ActionTemplate) == "" {
+		return data.Default, nil
+	}
+	data.Domain = options.Domain
+
+	var sb strings.Builder
+	if options.HtmlTemplate {
+		t, err := htmltemplate.New("action").Parse(options.ActionTemplate)
+		if err != nil {
+			return "", err
+		}
+		if err := t.Execute(&sb, data); err != nil {
+			return "", err
+		}
+		return sb.String(), nil
+	}
+
+	t, err := template.New("action").Parse(options.ActionTemplate)
+	if err != nil {
+		return "", err
+	}
+	if err := t.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
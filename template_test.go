@@ -0,0 +1,222 @@
+package domainverifier
+
+import (
+	"errors"
+	"github.com/egbakou/domainverifier/config"
+	"strings"
+	"testing"
+)
+
+// withTemplate returns options executing the default template of the method.
+func withTemplate(method Method) config.ActionOptions {
+	return config.ActionOptions{ActionTemplate: DefaultActionTemplate(method)}
+}
+
+func TestDefaultActionTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		generate func(options *config.ActionOptions) (string, error)
+	}{
+		{"html meta", func(o *config.ActionOptions) (string, error) {
+			c := &config.HmlMetaTagGenerator{TagName: "myapp", Code: "1234"}
+			c.ActionOptions = *o
+			i, err := GenerateHtmlMetaFromConfig(c, false)
+			return actionOf(i, err)
+		}},
+		{"html meta on a page", func(o *config.ActionOptions) (string, error) {
+			c := &config.HmlMetaTagGenerator{TagName: "myapp", Code: "1234", Path: "/shop/"}
+			c.ActionOptions = *o
+			i, err := GenerateHtmlMetaFromConfig(c, false)
+			return actionOf(i, err)
+		}},
+		{"json", func(o *config.ActionOptions) (string, error) {
+			c := &config.JsonGenerator{FileName: "myapp", Attribute: "myapp_site_verification", Code: "1234"}
+			c.ActionOptions = *o
+			i, err := GenerateJsonFromConfig(c, false)
+			return actionOf(i, err)
+		}},
+		{"xml in a directory", func(o *config.ActionOptions) (string, error) {
+			c := &config.XmlGenerator{FileName: "MyappSiteAuth.xml", RootName: "verification", Code: "1234", Path: "/.well-known"}
+			c.ActionOptions = *o
+			i, err := GenerateXmlFromConfig(c, false)
+			return actionOf(i, err)
+		}},
+		{"text file", func(o *config.ActionOptions) (string, error) {
+			c := &config.TextFileGenerator{FileName: "myapp1234.html", Content: "myapp-site-verification: myapp1234.html"}
+			c.ActionOptions = *o
+			i, err := GenerateTextFileFromConfig(c, false)
+			return actionOf(i, err)
+		}},
+		{"http header", func(o *config.ActionOptions) (string, error) {
+			c := &config.HttpHeaderGenerator{HeaderName: "X-Myapp-Site-Verification", Code: "1234"}
+			c.ActionOptions = *o
+			i, err := GenerateHttpHeaderFromConfig(c, false)
+			return actionOf(i, err)
+		}},
+		{"txt record", func(o *config.ActionOptions) (string, error) {
+			c := &config.TxtRecordGenerator{HostName: "@", RecordAttribute: "myapp", RecordAttributeValue: "1234"}
+			c.ActionOptions = *o
+			i, err := GenerateTxtRecordFromConfig(c, false)
+			return actionOf(i, err)
+		}},
+		{"cname record", func(o *config.ActionOptions) (string, error) {
+			c := &config.CnameRecordGenerator{RecordName: "1234", RecordTarget: "verify.myapp.com"}
+			c.ActionOptions = *o
+			i, err := GenerateCnameRecordFromConfig(c)
+			return actionOf(i, err)
+		}},
+	}
+	methods := []Method{MethodHtmlMeta, MethodHtmlMeta, MethodJson, MethodXml, MethodTextFile, MethodHttpHeader,
+		MethodTxtRecord, MethodCnameRecord}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := tt.generate(&config.ActionOptions{})
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			options := withTemplate(methods[i])
+			got, err := tt.generate(&options)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if got != want {
+				t.Errorf("expected: %q, got: %q", want, got)
+			}
+
+			// a language changes the sentences, not the lines of the action
+			localized, err := tt.generate(&config.ActionOptions{Language: "ja"})
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if strings.Count(localized, "\n") != strings.Count(want, "\n") || localized == want {
+				t.Errorf("expected the lines of %q, got: %q", want, localized)
+			}
+			extended, err := tt.generate(&config.ActionOptions{Language: "ja", ActionTemplate: "{{.Default}}"})
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if extended != localized {
+				t.Errorf("expected: %q, got: %q", localized, extended)
+			}
+		})
+	}
+}
+
+func TestDefaultActionTemplateCatalog(t *testing.T) {
+	original := Translate(MessageTxtRecordCreate, DefaultLanguage)
+	defer func() {
+		_ = RegisterCatalog(DefaultLanguage, Catalog{MessageTxtRecordCreate: original})
+	}()
+	if err := RegisterCatalog(DefaultLanguage, Catalog{MessageTxtRecordCreate: "Add the {{%s}} record %s:"}); err != nil {
+		t.Fatalf("RegisterCatalog() error = %v", err)
+	}
+
+	c := &config.TxtRecordGenerator{HostName: "@", RecordAttribute: "myapp", RecordAttributeValue: "1234"}
+	want := "Add the {{TXT}} record @:\nmyapp=1234"
+	for _, options := range []config.ActionOptions{{}, withTemplate(MethodTxtRecord)} {
+		c.ActionOptions = options
+		instruction, err := GenerateTxtRecordFromConfig(c, false)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if instruction.Action != want {
+			t.Errorf("expected: %q, got: %q", want, instruction.Action)
+		}
+	}
+}
+
+// actionOf returns the Action of any instruction.
+func actionOf(instruction interface{}, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	switch i := instruction.(type) {
+	case *HtmlMetaInstruction:
+		return i.Action, nil
+	case *FileInstruction:
+		return i.Action, nil
+	case *HttpHeaderInstruction:
+		return i.Action, nil
+	case *DnsRecordInstruction:
+		return i.Action, nil
+	}
+	return "", errors.New("unknown instruction")
+}
+
+func TestCustomActionTemplate(t *testing.T) {
+	type args struct {
+		options config.ActionOptions
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "text template with domain",
+			args: args{config.ActionOptions{
+				ActionTemplate: `Add {{.RecordType}} {{.HostName}}.{{.Domain}} = "{{.Record}}", see https://help.myapp.com/dns`,
+				Domain:         "website.com",
+			}},
+			want: `Add TXT _myapp-challenge.website.com = "myapp=<1234>", see https://help.myapp.com/dns`,
+		},
+		{
+			name: "html template escapes the values",
+			args: args{config.ActionOptions{
+				ActionTemplate: `<p>Add the <code>{{.Record}}</code> record</p>`,
+				HtmlTemplate:   true,
+			}},
+			want: `<p>Add the <code>myapp=&lt;1234&gt;</code> record</p>`,
+		},
+		{
+			name: "default action extended",
+			args: args{config.ActionOptions{ActionTemplate: `{{.Default}} (code {{.Code}})`}},
+			want: `Create a TXT record with the name _myapp-challenge and the content myapp=<1234> (code <1234>)`,
+		},
+		{
+			name:    "unknown field",
+			args:    args{config.ActionOptions{ActionTemplate: `{{.Unknown}}`}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid template",
+			args:    args{config.ActionOptions{ActionTemplate: `{{if .Code}}`}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := TemplateData{
+				Method:     MethodTxtRecord,
+				Code:       "<1234>",
+				HostName:   "_myapp-challenge",
+				Record:     "myapp=<1234>",
+				RecordType: "TXT",
+				Default:    "Create a TXT record with the name _myapp-challenge and the content myapp=<1234>",
+			}
+			got, err := executeActionTemplate(tt.args.options, data, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("executeActionTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestActionTemplateValidation(t *testing.T) {
+	c := &config.TxtRecordGenerator{HostName: "@", RecordAttribute: "myapp", RecordAttributeValue: "1234"}
+	c.ActionTemplate = "{{.HostName"
+	c.Domain = "not a domain"
+
+	_, err := GenerateTxtRecordFromConfig(c, false)
+	var validationErrors config.ValidationErrors
+	if !errors.As(err, &validationErrors) || len(validationErrors) != 2 {
+		t.Fatalf("expected 2 validation errors, got: %v", err)
+	}
+	if validationErrors[0].Field != "ActionTemplate" || !strings.Contains(validationErrors[1].Field, "Domain") {
+		t.Errorf("expected errors on ActionTemplate and Domain, got: %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package domainverifier
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
@@ -8,6 +10,8 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/egbakou/domainverifier/dnsresolver"
 	"github.com/miekg/dns"
+	"golang.org/x/net/html/charset"
+	"io"
 	"net/http"
 	"reflect"
 	"strings"
@@ -21,6 +25,66 @@ var InvalidDomainError = errors.New("invalid domain name")
 // InvalidResponseError indicates that the response is invalid
 var InvalidResponseError = errors.New("invalid response status code returned by the server")
 
+// InvalidPageError indicates that the page to check does not belong to the domain
+var InvalidPageError = errors.New("page URL must belong to the domain")
+
+// InvalidExpectedValueError indicates that the expected value cannot be used with the match mode
+var InvalidExpectedValueError = errors.New("expectedValue must be a struct or a map")
+
+// FileMatchMode defines how the content of a JSON or XML verification file is compared
+// with the expected value.
+type FileMatchMode int
+
+const (
+	// FileMatchExact requires the file content to be equal to the expected struct or map.
+	// A struct is compared with the file content decoded into the same struct type.
+	// An expected code also matches a file listing several codes, as a JSON array or repeated XML elements.
+	FileMatchExact FileMatchMode = iota
+	// FileMatchSubset requires every field of the expected struct or map to be present
+	// in the file content with an equal value. Extra fields are ignored.
+	FileMatchSubset
+	// FileMatchKeyPath requires the value found at FileMatchOptions.KeyPath to be equal
+	// to the expected value (e.g. a string), or to contain it when the file lists several codes.
+	FileMatchKeyPath
+)
+
+// FileMatchOptions customizes how the content of a JSON or XML verification file is matched.
+type FileMatchOptions struct {
+	Mode FileMatchMode
+	// KeyPath is the location of the expected value when Mode is FileMatchKeyPath,
+	// e.g. myapp_site_verification for JSON or /verification/code for XML.
+	// Segments are separated by slashes, or by dots when the path contains no slash.
+	// A backslash escapes the next character, see EscapeKeyPathSegment.
+	KeyPath string
+	// RootName is the required name of the root element of an XML file in the exact and subset modes,
+	// when the expected value is a map. The root element of an expected struct must have the name of the struct.
+	// A namespace prefix is ignored: ns:verification matches the root element verification of any namespace.
+	RootName string
+}
+
+// TextMatchMode defines how the content of a plain-text verification file is compared
+// with the expected content.
+type TextMatchMode int
+
+const (
+	// TextMatchExact requires the file content to be equal to the expected content.
+	TextMatchExact TextMatchMode = iota
+	// TextMatchContains requires the file content to contain the expected content, at a token boundary:
+	// a letter, a digit, - or _ cannot directly precede or follow it when it starts or ends with one
+	// (e.g. code: 1234 is not found in code: 12345).
+	TextMatchContains
+)
+
+// HtmlMetaOptions customizes how the HTML meta tag is searched for.
+type HtmlMetaOptions struct {
+	HeadOnly        bool // only consider meta tags inside <head>
+	MatchProperty   bool // also match the property attribute, e.g. <meta property="myapp:verification" ...>
+	CaseInsensitive bool // compare the tag name case-insensitively
+	// Path is the page holding the meta tag, either a URL path (e.g. /shop/)
+	// or a full URL on the verified domain. The home page is checked when empty.
+	Path string
+}
+
 // CheckHtmlMetaTag checks if the html meta tag exists and has the expected value
 //
 // Parameters:
@@ -33,10 +97,46 @@ var InvalidResponseError = errors.New("invalid response status code returned by
 //   - true if the ownership of the domain is verified
 //   - error if any
 func CheckHtmlMetaTag(domain, metaTagName, metaTagContent string) (bool, error) {
+	return CheckHtmlMetaTagWithOptions(domain, metaTagName, metaTagContent, nil)
+}
+
+// CheckHtmlMetaTagWithOptions checks if the html meta tag exists and has the expected value
+// using the provided options. All the meta tags matching metaTagName are considered.
+//
+// Parameters:
+//   - domain: the domain name to check
+//   - metaTagName: the name of the meta tag to check
+//   - metaTagContent: the expected value of the meta tag
+//   - options: the search options, nil for the defaults of CheckHtmlMetaTag
+//
+// Returns:
+//   - true if the ownership of the domain is verified
+//   - error if any
+//
+// Example:
+//
+//	options := &domainverify.HtmlMetaOptions{HeadOnly: true, CaseInsensitive: true, Path: "/shop/"}
+//	verified, err := domainverify.CheckHtmlMetaTagWithOptions("website.com", "msvalidate.01", "1234567890", options)
+func CheckHtmlMetaTagWithOptions(domain, metaTagName, metaTagContent string, options *HtmlMetaOptions) (bool, error) {
+	return checkHtmlMetaTag(context.Background(), nil, domain, metaTagName, metaTagContent, options)
+}
+
+// checkHtmlMetaTag is CheckHtmlMetaTagWithOptions with the context and the client of the request.
+func checkHtmlMetaTag(ctx context.Context, client *http.Client, domain, metaTagName, metaTagContent string,
+	options *HtmlMetaOptions) (bool, error) {
 	if !IsValidDomainName(domain) {
 		return false, InvalidDomainError
 	}
-	resp, err := makeHttpCall(domain)
+
+	location := domain
+	if options != nil {
+		var err error
+		if location, err = pageLocation(domain, options.Path); err != nil {
+			return false, err
+		}
+	}
+
+	resp, err := makeHttpCall(ctx, client, location)
 	if err != nil {
 		return false, err
 	}
@@ -46,23 +146,62 @@ func CheckHtmlMetaTag(domain, metaTagName, metaTagContent string) (bool, error)
 		return false, InvalidResponseError
 	}
 
-	// Load the HTML document
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := parseHtmlDocument(resp.Body, resp.Header.Get("Content-Type"))
 	if err != nil {
 		return false, err
 	}
 
-	// search for specified HTML meta tag and value
-	metaTag := doc.Find(fmt.Sprintf("meta[name=%s]", metaTagName))
-	if metaTag.Length() == 0 {
-		return false, nil
+	return findMetaTag(doc, metaTagName, metaTagContent, options), nil
+}
+
+// parseHtmlDocument loads an HTML document and transcodes it to UTF-8 first.
+// The character set is detected from the byte order mark, the Content-Type header
+// and the <meta charset> or <meta http-equiv> tags, in that order.
+func parseHtmlDocument(body io.Reader, contentType string) (*goquery.Document, error) {
+	reader, err := charset.NewReader(body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	return goquery.NewDocumentFromReader(reader)
+}
+
+// findMetaTag searches the document for a meta tag named metaTagName whose content is metaTagContent.
+// Attributes are compared in Go rather than through a CSS selector built from the tag name,
+// so names such as msvalidate.01 need no escaping.
+func findMetaTag(doc *goquery.Document, metaTagName, metaTagContent string, options *HtmlMetaOptions) bool {
+	if options == nil {
+		options = &HtmlMetaOptions{}
+	}
+
+	selector := "meta"
+	if options.HeadOnly {
+		selector = "head meta"
 	}
-	content, exists := metaTag.Attr("content")
-	if !exists {
-		return false, nil
+
+	attributes := []string{"name"}
+	if options.MatchProperty {
+		attributes = append(attributes, "property")
 	}
 
-	return content == metaTagContent, nil
+	found := false
+	doc.Find(selector).EachWithBreak(func(_ int, metaTag *goquery.Selection) bool {
+		for _, attribute := range attributes {
+			name, exists := metaTag.Attr(attribute)
+			if !exists {
+				continue
+			}
+			if name != metaTagName && !(options.CaseInsensitive && strings.EqualFold(name, metaTagName)) {
+				continue
+			}
+			if content, exists := metaTag.Attr("content"); exists && content == metaTagContent {
+				found = true
+				return false
+			}
+		}
+		return true
+	})
+
+	return found
 }
 
 // CheckJsonFile checks if the json file exists and has
@@ -70,7 +209,8 @@ func CheckHtmlMetaTag(domain, metaTagName, metaTagContent string) (bool, error)
 //
 // Parameters:
 //   - domain: the domain name to check
-//   - fileName: the name of the json file to check
+//   - fileName: the name of the json file to check, or its path relative to the root of the site
+//     (e.g. /.well-known/myapp-site-verification.json, see ResolveFilePath)
 //   - expectedValue: the expected content
 //
 // Returns:
@@ -87,7 +227,28 @@ func CheckHtmlMetaTag(domain, metaTagName, metaTagContent string) (bool, error)
 //	fileName := "myapp-site-verification.json" // excepted file content: {"myapp_site_verification": "1234567890"}
 //	verified, err := domainverify.CheckJsonFile(domain, fileName, data)
 func CheckJsonFile(domain, fileName string, expectedValue interface{}) (bool, error) {
-	return checkXmlOrJsonFile(false, domain, fileName, expectedValue)
+	return checkXmlOrJsonFile(context.Background(), nil, false, domain, fileName, expectedValue, nil)
+}
+
+// CheckJsonFileWithOptions checks if the json file exists and matches
+// the expected value according to options to verify ownership of the domain
+//
+// Parameters:
+//   - domain: the domain name to check
+//   - fileName: the name of the json file to check, or its path relative to the root of the site
+//   - expectedValue: a struct or a map, or the expected value at options.KeyPath
+//   - options: the match options, nil for the exact mode of CheckJsonFile
+//
+// Returns:
+//   - true if the ownership of the domain is verified
+//   - error if any
+//
+// Example:
+//
+//	options := &domainverify.FileMatchOptions{Mode: domainverify.FileMatchKeyPath, KeyPath: "myapp_site_verification"}
+//	verified, err := domainverify.CheckJsonFileWithOptions("website.com", "myapp-site-verification.json", "1234567890", options)
+func CheckJsonFileWithOptions(domain, fileName string, expectedValue interface{}, options *FileMatchOptions) (bool, error) {
+	return checkXmlOrJsonFile(context.Background(), nil, false, domain, fileName, expectedValue, options)
 }
 
 // CheckXmlFile checks if the xml file exists and has
@@ -95,7 +256,8 @@ func CheckJsonFile(domain, fileName string, expectedValue interface{}) (bool, er
 //
 // Parameters:
 //   - domain: the domain name to check
-//   - fileName: the name of the xml file to check
+//   - fileName: the name of the xml file to check, or its path relative to the root of the site
+//     (e.g. /.well-known/myappSiteAuth.xml, see ResolveFilePath)
 //   - expectedValue: the expected content
 //
 // Returns:
@@ -113,21 +275,152 @@ func CheckJsonFile(domain, fileName string, expectedValue interface{}) (bool, er
 //	fileName := "myappSiteAuth.xml" // excepted file content: <verification><code>1234567890</code></verification>
 //	verified, err := domainverify.CheckXmlFile(domain, fileName, data)
 func CheckXmlFile(domain, fileName string, expectedValue interface{}) (bool, error) {
-	return checkXmlOrJsonFile(true, domain, fileName, expectedValue)
+	return checkXmlOrJsonFile(context.Background(), nil, true, domain, fileName, expectedValue, nil)
+}
+
+// CheckXmlFileWithOptions checks if the xml file exists and matches
+// the expected value according to options to verify ownership of the domain.
+// In the exact and subset modes, a map describes the content of the root element, named options.RootName when set.
+//
+// Parameters:
+//   - domain: the domain name to check
+//   - fileName: the name of the xml file to check, or its path relative to the root of the site
+//   - expectedValue: a struct or a map, or the expected value at options.KeyPath
+//   - options: the match options, nil for the exact mode of CheckXmlFile
+//
+// Returns:
+//   - true if the ownership of the domain is verified
+//   - error if any
+//
+// Example:
+//
+//	options := &domainverify.FileMatchOptions{Mode: domainverify.FileMatchSubset}
+//	expectedValue := map[string]interface{}{"code": "1234567890"}
+//	verified, err := domainverify.CheckXmlFileWithOptions("website.com", "myappSiteAuth.xml", expectedValue, options)
+func CheckXmlFileWithOptions(domain, fileName string, expectedValue interface{}, options *FileMatchOptions) (bool, error) {
+	return checkXmlOrJsonFile(context.Background(), nil, true, domain, fileName, expectedValue, options)
+}
+
+// CheckTextFile checks if the plain-text file exists and has
+// the expected content to verify ownership of the domain.
+// Leading and trailing whitespace is ignored and every run of whitespace
+// is treated as a single space on both sides before comparison.
+//
+// Parameters:
+//   - domain: the domain name to check
+//   - fileName: the name of the text file to check, or its path relative to the root of the site
+//   - expectedContent: the expected content
+//   - matchMode: TextMatchExact to match the whole file body, TextMatchContains to search it
+//
+// Returns:
+//   - true if the ownership of the domain is verified
+//   - error if any
+//
+// Example:
+//
+//	domain := "website.com"
+//	fileName := "myapp1234567890.html" // excepted file content: myapp-site-verification: myapp1234567890.html
+//	expectedContent := "myapp-site-verification: myapp1234567890.html"
+//	verified, err := domainverify.CheckTextFile(domain, fileName, expectedContent, domainverify.TextMatchExact)
+func CheckTextFile(domain, fileName, expectedContent string, matchMode TextMatchMode) (bool, error) {
+	return checkTextFile(context.Background(), nil, domain, fileName, expectedContent, matchMode)
 }
 
-// checkXmlOrJsonFile checks domain name ownership using Xml or Json method
-func checkXmlOrJsonFile(useXmlMethod bool, domain, fileName string, expectedValue interface{}) (bool, error) {
+// checkTextFile is CheckTextFile with the context and the client of the request.
+func checkTextFile(ctx context.Context, client *http.Client, domain, fileName, expectedContent string,
+	matchMode TextMatchMode) (bool, error) {
 	if !IsValidDomainName(domain) {
 		return false, InvalidDomainError
 	}
 
-	// Only struct type is supported
-	if reflect.TypeOf(expectedValue).Kind() != reflect.Struct {
-		return false, errors.New("expectedValue must be a struct")
+	resp, err := makeHttpCall(ctx, client, fileLocation(domain, fileName))
+	if err != nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, InvalidResponseError
 	}
 
-	resp, err := makeHttpCall(fmt.Sprintf("%s/%s", domain, fileName))
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+
+	return matchTextContent(string(body), expectedContent, matchMode), nil
+}
+
+// CheckHttpHeader checks if the home page of the domain is served with
+// the expected HTTP response header to verify ownership of the domain.
+// A HEAD request is made first, falling back to GET when the server does not support it
+// or does not send the header in response to HEAD (some servers only add it to GET responses).
+//
+// Parameters:
+//   - domain: the domain name to check
+//   - headerName: the name of the response header (case-insensitive)
+//   - headerValue: the expected value of the response header
+//
+// Returns:
+//   - true if the ownership of the domain is verified
+//   - error if any
+//
+// Example:
+//
+//	domain := "website.com"
+//	headerName := "X-Myapp-Site-Verification"
+//	headerValue := "1234567890"
+//	verified, err := domainverify.CheckHttpHeader(domain, headerName, headerValue)
+func CheckHttpHeader(domain, headerName, headerValue string) (bool, error) {
+	return checkHttpHeader(context.Background(), nil, domain, headerName, headerValue)
+}
+
+// checkHttpHeader is CheckHttpHeader with the context and the client of the requests.
+func checkHttpHeader(ctx context.Context, client *http.Client, domain, headerName, headerValue string) (bool, error) {
+	if !IsValidDomainName(domain) {
+		return false, InvalidDomainError
+	}
+
+	resp, err := makeHttpRequest(ctx, client, http.MethodHead, domain)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK && matchHeaderValue(resp.Header, headerName, headerValue) {
+			return true, nil
+		}
+	}
+
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	resp, err = makeHttpCall(ctx, client, domain)
+	if err != nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, InvalidResponseError
+	}
+
+	return matchHeaderValue(resp.Header, headerName, headerValue), nil
+}
+
+// checkXmlOrJsonFile checks domain name ownership using Xml or Json method,
+// with the context and the client of the request.
+func checkXmlOrJsonFile(ctx context.Context, client *http.Client, useXmlMethod bool, domain, fileName string,
+	expectedValue interface{}, options *FileMatchOptions) (bool, error) {
+	if !IsValidDomainName(domain) {
+		return false, InvalidDomainError
+	}
+
+	if options == nil {
+		options = &FileMatchOptions{}
+	}
+	if err := validateExpectedValue(expectedValue, options); err != nil {
+		return false, err
+	}
+
+	resp, err := makeHttpCall(ctx, client, fileLocation(domain, fileName))
 	if err != nil {
 		return false, err
 	}
@@ -137,22 +430,84 @@ func checkXmlOrJsonFile(useXmlMethod bool, domain, fileName string, expectedValu
 		return false, InvalidResponseError
 	}
 
-	// Decode the XML response from the URL
-	decodedValue := reflect.New(reflect.TypeOf(expectedValue)).Interface()
-	if useXmlMethod {
-		err = xml.NewDecoder(resp.Body).Decode(decodedValue)
-	} else {
-		err = json.NewDecoder(resp.Body).Decode(decodedValue)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+
+	// Structs are decoded into a new instance of their own type, as the exact mode always did.
+	// A file listing several codes (a JSON array or repeated XML elements) does not fit a struct
+	// holding a single code, so it is compared as a generic document below.
+	if options.Mode == FileMatchExact && reflect.TypeOf(expectedValue).Kind() == reflect.Struct {
+		decodedValue := reflect.New(reflect.TypeOf(expectedValue)).Interface()
+		if useXmlMethod {
+			err = xml.Unmarshal(body, decodedValue)
+		} else {
+			err = json.Unmarshal(body, decodedValue)
+		}
+
+		if err == nil && reflect.DeepEqual(reflect.ValueOf(decodedValue).Elem().Interface(), expectedValue) {
+			return true, nil
+		}
 	}
 
+	actualValue, err := decodeDocument(useXmlMethod, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	mustMatchValue, rootName, err := normalizeExpectedValue(useXmlMethod, expectedValue)
 	if err != nil {
 		return false, err
 	}
 
-	actualValue := reflect.ValueOf(decodedValue).Elem()
-	mustMatchValue := reflect.ValueOf(expectedValue)
+	return matchDocument(useXmlMethod, actualValue, mustMatchValue, rootName, options), nil
+}
+
+// validateExpectedValue checks that the expected value can be used with the match mode.
+func validateExpectedValue(expectedValue interface{}, options *FileMatchOptions) error {
+	if expectedValue == nil {
+		return InvalidExpectedValueError
+	}
+
+	if options.Mode == FileMatchKeyPath {
+		if strings.TrimSpace(options.KeyPath) == "" {
+			return errors.New("key path cannot be empty")
+		}
+		return nil
+	}
+
+	// Only struct and map types are supported
+	kind := reflect.TypeOf(expectedValue).Kind()
+	if kind != reflect.Struct && kind != reflect.Map {
+		return InvalidExpectedValueError
+	}
+	return nil
+}
+
+// matchDocument compares a decoded document with the normalized expected value.
+// For XML, the expected struct or map of the exact and subset modes describes the content of the root element,
+// which must be named rootName, the name of an expected struct, or options.RootName.
+func matchDocument(useXml bool, actualValue, expectedValue interface{}, rootName string, options *FileMatchOptions) bool {
+	if options.Mode == FileMatchKeyPath {
+		value, found := lookupKeyPath(actualValue, options.KeyPath)
+		return found && isSubset(expectedValue, value)
+	}
+
+	if useXml {
+		if rootName == "" {
+			rootName = options.RootName
+		}
+		var actualRootName string
+		actualRootName, actualValue = xmlRoot(actualValue)
+		if rootName != "" && actualRootName != xmlLocalName(rootName) {
+			return false
+		}
+	}
 
-	return actualValue.Interface() == mustMatchValue.Interface(), nil
+	if options.Mode == FileMatchSubset {
+		return isSubset(expectedValue, actualValue)
+	}
+	return isExactMatch(expectedValue, actualValue)
 }
 
 // CheckTxtRecord checks if the domain has a DNS TXT record
@@ -161,7 +516,8 @@ func checkXmlOrJsonFile(useXmlMethod bool, domain, fileName string, expectedValu
 // Parameters:
 //   - dnsResolver: the DNS server to use
 //   - domain: the domain name to verify
-//   - hostName: the TXT record name
+//   - hostName: the TXT record name: @ for the apex, a name relative to the domain (e.g. _myapp-challenge)
+//     or including it (e.g. _myapp-challenge.website.com). A CNAME record delegating hostName is followed.
 //   - recordContent: the content of the TXT record
 //
 // Returns:
@@ -203,7 +559,17 @@ func CheckCnameRecord(dnsResolver, domain, recordName, targetValue string) (bool
 	return checkDNSRecord(dnsResolver, domain, recordName, targetValue, dns.TypeCNAME)
 }
 
+// maxCnameChain is the maximum number of CNAME records followed while looking for a TXT record.
+const maxCnameChain = 8
+
 func checkDNSRecord(dnsResolver, domain, recordName, recordContent string, recordType uint16) (bool, error) {
+	return checkDNSRecordContext(context.Background(), dnsResolver, domain, recordName, recordContent, recordType, nil)
+}
+
+// checkDNSRecordContext is checkDNSRecord with a context canceling the queries,
+// calling observe, when not nil, with each answer of the resolver (e.g. to read its TTL).
+func checkDNSRecordContext(ctx context.Context, dnsResolver, domain, recordName, recordContent string, recordType uint16,
+	observe func(r *dns.Msg)) (bool, error) {
 	if strings.TrimSpace(dnsResolver) == "" {
 		dnsResolver = dnsresolver.CloudflareDNS
 	}
@@ -212,39 +578,91 @@ func checkDNSRecord(dnsResolver, domain, recordName, recordContent string, recor
 		return false, InvalidDomainError
 	}
 
-	if recordName != rootDomain && recordName != domain {
-		domain = fmt.Sprintf("%s.%s", recordName, domain)
+	name := dnsRecordName(domain, recordName)
+	if recordType == dns.TypeCNAME && !strings.HasSuffix(recordContent, ".") {
+		recordContent = fmt.Sprintf("%s.", recordContent)
 	}
 
-	c := dns.Client{}
-	m := dns.Msg{}
-	m.SetQuestion(dns.Fqdn(domain), recordType)
-	r, _, err := c.Exchange(&m, dnsResolver)
-	if err != nil {
-		return false, err
-	}
+	// A TXT record name can be delegated to another zone with a CNAME record (e.g. _myapp-challenge.website.com
+	// pointing to website-com.challenges.myapp.com). Recursive resolvers usually return the whole chain,
+	// otherwise the CNAME targets are queried until the TXT record is found.
+	for i := 0; i <= maxCnameChain; i++ {
+		r, err := exchangeDNSContext(ctx, dnsResolver, name, recordType)
+		if err != nil {
+			return false, err
+		}
+		if observe != nil {
+			observe(r)
+		}
 
-	if r.Rcode != dns.RcodeSuccess {
-		return false, nil
-	}
+		if r.Rcode != dns.RcodeSuccess {
+			return false, nil
+		}
 
-	for _, a := range r.Answer {
-		switch t := a.(type) {
-		case *dns.TXT:
-			for _, txt := range t.Txt {
-				if txt == recordContent {
-					return true, nil
+		target := ""
+		for _, a := range r.Answer {
+			switch t := a.(type) {
+			case *dns.TXT:
+				for _, txt := range t.Txt {
+					if txt == recordContent {
+						return true, nil
+					}
+				}
+			case *dns.CNAME:
+				if recordType == dns.TypeCNAME {
+					if t.Target == recordContent {
+						return true, nil
+					}
+					continue
+				}
+				if target == "" && strings.EqualFold(t.Hdr.Name, name) {
+					target = t.Target
 				}
 			}
-		case *dns.CNAME:
-			if !strings.HasSuffix(recordContent, ".") {
-				recordContent = fmt.Sprintf("%s.", recordContent)
-			}
-			if t.Target == recordContent {
-				return true, nil
-			}
 		}
+
+		if recordType != dns.TypeTXT || target == "" || hasRecordType(r.Answer, dns.TypeTXT) {
+			return false, nil
+		}
+		name = target
 	}
 
 	return false, nil
 }
+
+// dnsRecordName returns the fully qualified name of a record of the domain.
+// recordName can be @ for the apex of the domain, a name relative to the domain (e.g. _myapp-challenge)
+// or a name already including the domain (e.g. _myapp-challenge.website.com).
+func dnsRecordName(domain, recordName string) string {
+	recordName = strings.TrimSuffix(strings.TrimSpace(recordName), ".")
+	lowerName, lowerDomain := strings.ToLower(recordName), strings.ToLower(domain)
+	switch {
+	case recordName == "" || recordName == rootDomain || lowerName == lowerDomain:
+		return dns.Fqdn(domain)
+	case strings.HasSuffix(lowerName, "."+lowerDomain):
+		return dns.Fqdn(recordName)
+	default:
+		return dns.Fqdn(fmt.Sprintf("%s.%s", recordName, domain))
+	}
+}
+
+func exchangeDNS(dnsResolver, name string, recordType uint16) (*dns.Msg, error) {
+	return exchangeDNSContext(context.Background(), dnsResolver, name, recordType)
+}
+
+func exchangeDNSContext(ctx context.Context, dnsResolver, name string, recordType uint16) (*dns.Msg, error) {
+	c := dns.Client{}
+	m := dns.Msg{}
+	m.SetQuestion(name, recordType)
+	r, _, err := c.ExchangeContext(ctx, &m, dnsResolver)
+	return r, err
+}
+
+func hasRecordType(records []dns.RR, recordType uint16) bool {
+	for _, record := range records {
+		if record.Header().Rrtype == recordType {
+			return true
+		}
+	}
+	return false
+}
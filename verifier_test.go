@@ -1,7 +1,16 @@
 package domainverifier
 
 import (
+	"context"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/egbakou/domainverifier/config"
 	"github.com/egbakou/domainverifier/dnsresolver"
+	"github.com/miekg/dns"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -62,6 +71,101 @@ func TestCheckHtmlMetaTag(t *testing.T) {
 	}
 }
 
+func TestFindMetaTag(t *testing.T) {
+	const page = `<html>
+<head>
+	<meta name="msvalidate.01" content="old-code" />
+	<meta name="msvalidate.01" content="1234567890" />
+	<meta property="myapp:verification" content="property-code" />
+	<meta name="MyApp-Site-Verification" content="mixed-case-code" />
+</head>
+<body>
+	<meta name="body-verification" content="body-code" />
+</body>
+</html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("failed to parse page: %v", err)
+	}
+
+	type args struct {
+		name    string
+		content string
+		options *HtmlMetaOptions
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{"dotted tag name", args{"msvalidate.01", "1234567890", nil}, true},
+		{"first of several matching tags", args{"msvalidate.01", "old-code", nil}, true},
+		{"wrong content", args{"msvalidate.01", "0987654321", nil}, false},
+		{"property ignored by default", args{"myapp:verification", "property-code", nil}, false},
+		{"property", args{"myapp:verification", "property-code", &HtmlMetaOptions{MatchProperty: true}}, true},
+		{"case-sensitive by default", args{"myapp-site-verification", "mixed-case-code", nil}, false},
+		{"case-insensitive", args{"myapp-site-verification", "mixed-case-code", &HtmlMetaOptions{CaseInsensitive: true}}, true},
+		{"body tag", args{"body-verification", "body-code", nil}, true},
+		{"body tag with head only", args{"body-verification", "body-code", &HtmlMetaOptions{HeadOnly: true}}, false},
+		{"quotes in tag name", args{`a"]`, "1234567890", nil}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findMetaTag(doc, tt.args.name, tt.args.content, tt.args.options)
+			if got != tt.want {
+				t.Errorf("findMetaTag() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHtmlDocument(t *testing.T) {
+	tests := []struct {
+		name        string
+		fixture     string
+		contentType string
+		content     string
+	}{
+		{
+			name:        "shift_jis declared in meta charset",
+			fixture:     "testdata/shift_jis.html",
+			contentType: "text/html",
+			content:     "検証コード-1234",
+		},
+		{
+			name:        "windows-1251 declared in Content-Type",
+			fixture:     "testdata/windows-1251.html",
+			contentType: "text/html; charset=windows-1251",
+			content:     "проверка-1234",
+		},
+		{
+			name:        "utf-8 with byte order mark",
+			fixture:     "testdata/utf8-bom.html",
+			contentType: "text/html; charset=iso-8859-1",
+			content:     "vérification-1234",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := os.Open(tt.fixture)
+			if err != nil {
+				t.Fatalf("failed to open fixture: %v", err)
+			}
+			defer page.Close()
+
+			doc, err := parseHtmlDocument(page, tt.contentType)
+			if err != nil {
+				t.Fatalf("parseHtmlDocument() error = %v", err)
+			}
+			if !findMetaTag(doc, "myapp-site-verification", tt.content, nil) {
+				got, _ := doc.Find("meta[name='myapp-site-verification']").Attr("content")
+				t.Errorf("parseHtmlDocument() content = %q, want %q", got, tt.content)
+			}
+		})
+	}
+}
+
 type ownershipVerification struct {
 	Code string `xml:"code" json:"myapp_site_verification"`
 }
@@ -181,6 +285,152 @@ func TestCheckXmlFile(t *testing.T) {
 	}
 }
 
+func TestCheckFileMultipleCodesRoundTrip(t *testing.T) {
+	jsonInstruction, err := GenerateJsonFromConfig(&config.JsonGenerator{
+		FileName:  "myapp-site-verification.json",
+		Attribute: "myapp_site_verification",
+		Code:      "1234",
+		Codes:     []string{"5678"},
+	}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	xmlInstruction, err := GenerateXmlFromConfig(&config.XmlGenerator{
+		FileName: "myappSiteAuth.xml",
+		RootName: "verification",
+		Code:     "1234",
+		Codes:    []string{"5678"},
+	}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := startTestWebServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case jsonInstruction.Path:
+			_, _ = w.Write([]byte(jsonInstruction.FileContent))
+		case xmlInstruction.Path:
+			_, _ = w.Write([]byte(xmlInstruction.FileContent))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	ctx := context.Background()
+	for _, code := range []string{"1234", "5678", "other"} {
+		want := code != "other"
+		if got, err := checkXmlOrJsonFile(ctx, client, false, "website.com", jsonInstruction.Path, ownershipVerification{Code: code}, nil); err != nil || got != want {
+			t.Errorf("json code %s: expected: %v, got: %v (%v)", code, want, got, err)
+		}
+		if got, err := checkXmlOrJsonFile(ctx, client, true, "website.com", xmlInstruction.Path, sharedOwnershipVerification{Code: code}, nil); err != nil || got != want {
+			t.Errorf("xml code %s: expected: %v, got: %v (%v)", code, want, got, err)
+		}
+	}
+}
+
+func TestCheckTextFile(t *testing.T) {
+	client := startTestWebServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/myapp1234.html":
+			_, _ = w.Write([]byte("myapp-site-verification: myapp1234.html\n"))
+		case "/page.html":
+			_, _ = w.Write([]byte("<html><body>\ncode: 12345\n</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	tests := []struct {
+		name     string
+		fileName string
+		expected string
+		mode     TextMatchMode
+		want     bool
+		wantErr  bool
+	}{
+		{"exact match", "myapp1234.html", "myapp-site-verification: myapp1234.html", TextMatchExact, true, false},
+		{"exact mismatch", "myapp1234.html", "myapp-site-verification", TextMatchExact, false, false},
+		{"contains match", "page.html", "code: 12345", TextMatchContains, true, false},
+		{"contains shorter code", "page.html", "code: 1234", TextMatchContains, false, false},
+		{"file not found", "missing.html", "code: 12345", TextMatchContains, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkTextFile(context.Background(), client, "website.com", tt.fileName, tt.expected, tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckHttpHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "header present",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Myapp-Site-Verification", "1234")
+			},
+			want: true,
+		},
+		{
+			name: "header missing",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Other", "1234")
+			},
+			want: false,
+		},
+		{
+			name: "header only sent with GET",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					w.Header().Set("X-Myapp-Site-Verification", "1234")
+				}
+			},
+			want: true,
+		},
+		{
+			name: "HEAD not allowed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+				w.Header().Set("X-Myapp-Site-Verification", "1234")
+			},
+			want: true,
+		},
+		{
+			name: "home page not found",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Myapp-Site-Verification", "1234")
+				w.WriteHeader(http.StatusNotFound)
+			},
+			want:    false,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := startTestWebServer(t, tt.handler)
+			got, err := checkHttpHeader(context.Background(), client, "website.com", "x-myapp-site-verification", "1234")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestCheckTxtRecord(t *testing.T) {
 	type args struct {
 		dnsResolver   string
@@ -323,3 +573,120 @@ func TestCheckCnameRecord(t *testing.T) {
 		})
 	}
 }
+
+// startTestDNSServer starts a local DNS server answering with the given records, keyed by question name.
+// Each answer only holds the records of the question name, like an authoritative server that does not
+// resolve CNAME records. It returns the address of the server.
+func startTestDNSServer(t *testing.T, records map[string][]string) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		rrs, exists := records[strings.ToLower(r.Question[0].Name)]
+		if !exists {
+			m.Rcode = dns.RcodeNameError
+		}
+		for _, record := range rrs {
+			rr, err := dns.NewRR(record)
+			if err != nil {
+				t.Errorf("invalid record %q: %v", record, err)
+				continue
+			}
+			m.Answer = append(m.Answer, rr)
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	server := &dns.Server{PacketConn: conn, Handler: handler}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+	return conn.LocalAddr().String()
+}
+
+// startTestWebServer starts a local HTTP server and returns a client routing the requests to it,
+// whatever the domain. The server is closed at the end of the test.
+func startTestWebServer(t *testing.T, handler http.Handler) *http.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}}
+	t.Cleanup(server.Close)
+	return client
+}
+
+func TestCheckDNSRecordLocal(t *testing.T) {
+	resolver := startTestDNSServer(t, map[string][]string{
+		"website.com.":                      {`website.com. 60 IN TXT "v=spf1 -all" "myapp-site-verification=apex"`},
+		"_myapp-challenge.website.com.":     {`_myapp-challenge.website.com. 60 IN TXT "myapp-site-verification=1234"`},
+		"_other-challenge.website.com.":     {`_other-challenge.website.com. 60 IN CNAME website-com.challenges.myapp.com.`},
+		"website-com.challenges.myapp.com.": {`website-com.challenges.myapp.com. 60 IN TXT "myapp-site-verification=5678"`},
+		"_loop-challenge.website.com.":      {`_loop-challenge.website.com. 60 IN CNAME _loop-challenge.website.com.`},
+		"verify.website.com.":               {`verify.website.com. 60 IN CNAME verify.myapp.com.`},
+	})
+
+	type args struct {
+		hostName      string
+		recordContent string
+		recordType    uint16
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{"apex txt", args{"@", "myapp-site-verification=apex", dns.TypeTXT}, true},
+		{"challenge label", args{"_myapp-challenge", "myapp-site-verification=1234", dns.TypeTXT}, true},
+		{"challenge label with domain", args{"_myapp-challenge.website.com", "myapp-site-verification=1234", dns.TypeTXT}, true},
+		{"challenge label with wrong content", args{"_myapp-challenge", "myapp-site-verification=5678", dns.TypeTXT}, false},
+		{"delegated challenge label", args{"_other-challenge", "myapp-site-verification=5678", dns.TypeTXT}, true},
+		{"cname loop", args{"_loop-challenge", "myapp-site-verification=5678", dns.TypeTXT}, false},
+		{"missing label", args{"_missing-challenge", "myapp-site-verification=1234", dns.TypeTXT}, false},
+		{"cname record", args{"verify", "verify.myapp.com", dns.TypeCNAME}, true},
+		{"cname record with wrong target", args{"verify", "other.myapp.com", dns.TypeCNAME}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkDNSRecord(resolver, "website.com", tt.args.hostName, tt.args.recordContent, tt.args.recordType)
+			if err != nil {
+				t.Fatalf("checkDNSRecord() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("checkDNSRecord() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDnsRecordName(t *testing.T) {
+	tests := []struct {
+		recordName string
+		want       string
+	}{
+		{"@", "website.com."},
+		{"", "website.com."},
+		{"website.com", "website.com."},
+		{"_myapp-challenge", "_myapp-challenge.website.com."},
+		{"_myapp-challenge.website.com", "_myapp-challenge.website.com."},
+		{"_myapp-challenge.Website.com.", "_myapp-challenge.Website.com."},
+		{"verify.otherwebsite.com", "verify.otherwebsite.com.website.com."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.recordName, func(t *testing.T) {
+			if got := dnsRecordName("website.com", tt.recordName); got != tt.want {
+				t.Errorf("dnsRecordName() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
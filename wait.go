@@ -0,0 +1,175 @@
+package domainverifier
+
+import (
+	"context"
+	"github.com/miekg/dns"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultWaitInterval is the interval before the first retry of Wait, when none is configured.
+	DefaultWaitInterval = 2 * time.Second
+	// DefaultMaxWaitInterval is the longest interval between two retries of Wait, when none is configured.
+	DefaultMaxWaitInterval = time.Minute
+	// DefaultWaitMultiplier is the factor the interval of Wait grows by after each retry, when none is configured.
+	DefaultWaitMultiplier = 2.0
+)
+
+// WaitOptions configures Wait.
+type WaitOptions struct {
+	InitialInterval time.Duration // interval before the first retry, DefaultWaitInterval when 0
+	MaxInterval     time.Duration // longest interval between two retries, DefaultMaxWaitInterval when 0
+	Multiplier      float64       // factor the interval grows by after each retry, DefaultWaitMultiplier when lower than 1
+	// Jitter randomizes each interval by up to this fraction of it (e.g. 0.2 for ±20%). No jitter when 0.
+	Jitter      float64
+	DnsResolver string                      // DNS server of the TXT and CNAME checks, dnsresolver.CloudflareDNS when empty
+	HttpClient  *http.Client                // client of the HTTP checks, a client with DefaultHttpTimeout when nil
+	OnProgress  func(progress WaitProgress) // optional, called after each attempt
+}
+
+// WaitProgress reports an attempt of Wait.
+type WaitProgress struct {
+	Attempt   int
+	Method    Method // method that verified the domain, empty when not verified
+	Verified  bool
+	Err       error         // error of the check, if any
+	Elapsed   time.Duration // time since Wait was called
+	NextRetry time.Duration // interval before the next attempt, 0 when verified
+}
+
+// Wait checks the challenge until the ownership of its domain is verified or the context is done,
+// e.g. right after the user was told to create a TXT record. The interval between two attempts grows
+// exponentially, with an optional jitter. For the DNS methods, it is never shorter than the TTL of the answers
+// of the resolver, since the resolver caches them and a new attempt would get the same answers, unless the TTL
+// exceeds MaxInterval. The context also cancels the DNS queries and the HTTP requests of an attempt.
+// It returns the method that verified the domain, or the context error.
+// The status of the challenge is left untouched, see LifecycleManager.Attempt.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+//	defer cancel()
+//	method, err := domainverifier.Wait(ctx, challenge, &domainverifier.WaitOptions{
+//		Jitter: 0.2,
+//		OnProgress: func(progress domainverifier.WaitProgress) {
+//			fmt.Printf("attempt %d, next in %v\n", progress.Attempt, progress.NextRetry)
+//		},
+//	})
+func Wait(ctx context.Context, challenge *Challenge, options *WaitOptions) (Method, error) {
+	if err := challenge.validate(); err != nil {
+		return "", err
+	}
+	o := WaitOptions{}
+	if options != nil {
+		o = *options
+	}
+	if o.InitialInterval <= 0 {
+		o.InitialInterval = DefaultWaitInterval
+	}
+	if o.MaxInterval <= 0 {
+		o.MaxInterval = DefaultMaxWaitInterval
+	}
+	if o.Multiplier < 1 {
+		o.Multiplier = DefaultWaitMultiplier
+	}
+
+	start := time.Now()
+	interval := o.InitialInterval
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		var ttl dnsTTL
+		method, verified, err := challenge.check(ctx, checkOptions{dnsResolver: o.DnsResolver, httpClient: o.HttpClient, observe: ttl.observe})
+		progress := WaitProgress{Attempt: attempt, Method: method, Verified: verified, Err: err, Elapsed: time.Since(start)}
+		if verified {
+			if o.OnProgress != nil {
+				o.OnProgress(progress)
+			}
+			return method, nil
+		}
+
+		progress.NextRetry = jitterInterval(interval, o.Jitter)
+		if minInterval := ttl.min(o.MaxInterval); progress.NextRetry < minInterval {
+			progress.NextRetry = minInterval
+		}
+		if o.OnProgress != nil {
+			o.OnProgress(progress)
+		}
+
+		timer := time.NewTimer(progress.NextRetry)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		case <-timer.C:
+		}
+
+		interval = time.Duration(float64(interval) * o.Multiplier)
+		if interval > o.MaxInterval {
+			interval = o.MaxInterval
+		}
+	}
+}
+
+// jitterInterval randomizes the interval by up to ±jitter of it.
+func jitterInterval(interval time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+	return time.Duration(float64(interval) * (1 + jitter*(2*rand.Float64()-1)))
+}
+
+// dnsTTL records the shortest TTL of the answers of the resolver during an attempt:
+// the TTL of the records when they exist, or the negative caching TTL of the SOA record otherwise.
+type dnsTTL struct {
+	ttl    uint32
+	cached bool
+}
+
+func (t *dnsTTL) observe(r *dns.Msg) {
+	if ttl, found := answerTTL(r); found && (!t.cached || ttl < t.ttl) {
+		t.ttl, t.cached = ttl, true
+	}
+}
+
+// min returns the recorded TTL, capped at maxInterval, or 0 when no answer was received.
+func (t *dnsTTL) min(maxInterval time.Duration) time.Duration {
+	ttl := time.Duration(t.ttl) * time.Second
+	if ttl > maxInterval {
+		return maxInterval
+	}
+	return ttl
+}
+
+// answerTTL returns the time the answer can be cached: the shortest TTL of its records,
+// or for a negative answer the lowest of the TTL and the minimum field of the SOA record (RFC 2308).
+func answerTTL(r *dns.Msg) (uint32, bool) {
+	var ttl uint32
+	found := false
+	for _, rr := range r.Answer {
+		if !found || rr.Header().Ttl < ttl {
+			ttl, found = rr.Header().Ttl, true
+		}
+	}
+	if found {
+		return ttl, true
+	}
+
+	for _, rr := range r.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			ttl = soa.Hdr.Ttl
+			if soa.Minttl < ttl {
+				ttl = soa.Minttl
+			}
+			return ttl, true
+		}
+	}
+	return 0, false
+}
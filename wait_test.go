@@ -0,0 +1,161 @@
+package domainverifier
+
+import (
+	"context"
+	"github.com/egbakou/domainverifier/config"
+	"github.com/miekg/dns"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestWaitChallenge(domain string) *Challenge {
+	return &Challenge{
+		ID:      "1",
+		Domain:  domain,
+		Method:  MethodTxtRecord,
+		Methods: &config.Methods{TxtRecord: &config.TxtRecordGenerator{HostName: "_myapp-challenge", RecordAttribute: "myapp", RecordAttributeValue: "1234"}},
+	}
+}
+
+func TestWait(t *testing.T) {
+	resolver := startTestDNSServer(t, map[string][]string{
+		"_myapp-challenge.website.com.": {`_myapp-challenge.website.com. 0 IN TXT "myapp=1234"`},
+	})
+
+	var progress []WaitProgress
+	method, err := Wait(context.Background(), newTestWaitChallenge("website.com"), &WaitOptions{
+		DnsResolver: resolver,
+		OnProgress:  func(p WaitProgress) { progress = append(progress, p) },
+	})
+	if err != nil || method != MethodTxtRecord {
+		t.Errorf("expected: %v, got: %v (%v)", MethodTxtRecord, method, err)
+	}
+	if len(progress) != 1 || !progress[0].Verified || progress[0].NextRetry != 0 {
+		t.Errorf("expected a single verified attempt, got: %+v", progress)
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	resolver := startTestDNSServer(t, map[string][]string{})
+
+	var progress []WaitProgress
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	method, err := Wait(ctx, newTestWaitChallenge("website.com"), &WaitOptions{
+		InitialInterval: 10 * time.Millisecond,
+		MaxInterval:     20 * time.Millisecond,
+		Jitter:          0.5,
+		DnsResolver:     resolver,
+		OnProgress:      func(p WaitProgress) { progress = append(progress, p) },
+	})
+	if err != context.DeadlineExceeded || method != "" {
+		t.Errorf("expected: %v, got: %v %v", context.DeadlineExceeded, method, err)
+	}
+	if len(progress) < 3 {
+		t.Fatalf("expected several attempts, got: %v", len(progress))
+	}
+	for i, p := range progress {
+		if p.Attempt != i+1 || p.Verified || p.NextRetry < 5*time.Millisecond || p.NextRetry > 30*time.Millisecond {
+			t.Errorf("unexpected progress: %+v", p)
+		}
+	}
+}
+
+func TestWaitTTL(t *testing.T) {
+	resolver := startTestDNSServer(t, map[string][]string{
+		"_myapp-challenge.website.com.": {`_myapp-challenge.website.com. 1 IN TXT "myapp=old"`},
+		"_myapp-challenge.other.com.":   {`_myapp-challenge.other.com. 3600 IN TXT "a"`, `_myapp-challenge.other.com. 1800 IN TXT "b"`},
+	})
+
+	tests := []struct {
+		domain      string
+		maxInterval time.Duration
+		want        time.Duration
+	}{
+		{"website.com", 5 * time.Second, time.Second},
+		{"other.com", 20 * time.Millisecond, 20 * time.Millisecond},
+		{"unknown.com", 5 * time.Second, 10 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			var progress []WaitProgress
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, _ = Wait(ctx, newTestWaitChallenge(tt.domain), &WaitOptions{
+				InitialInterval: 10 * time.Millisecond,
+				MaxInterval:     tt.maxInterval,
+				DnsResolver:     resolver,
+				OnProgress:      func(p WaitProgress) { progress = append(progress, p) },
+			})
+			if len(progress) == 0 || progress[0].NextRetry != tt.want {
+				t.Errorf("expected: %v, got: %+v", tt.want, progress)
+			}
+		})
+	}
+}
+
+func TestWaitCancelsDNSQueries(t *testing.T) {
+	// a resolver that never answers
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := Wait(ctx, newTestWaitChallenge("website.com"), &WaitOptions{DnsResolver: conn.LocalAddr().String()}); err != context.DeadlineExceeded {
+		t.Errorf("expected: %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the queries to be canceled with the context, waited: %v", elapsed)
+	}
+}
+
+func TestAnswerTTL(t *testing.T) {
+	soa, _ := dns.NewRR("website.com. 3600 IN SOA ns1.website.com. admin.website.com. 1 7200 900 1209600 300")
+	txt, _ := dns.NewRR(`website.com. 120 IN TXT "a"`)
+
+	tests := []struct {
+		name      string
+		msg       *dns.Msg
+		want      uint32
+		wantFound bool
+	}{
+		{"answer", &dns.Msg{Answer: []dns.RR{txt}}, 120, true},
+		{"negative answer", &dns.Msg{Ns: []dns.RR{soa}}, 300, true},
+		{"empty", &dns.Msg{}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := answerTTL(tt.msg)
+			if got != tt.want || found != tt.wantFound {
+				t.Errorf("expected: %v %v, got: %v %v", tt.want, tt.wantFound, got, found)
+			}
+		})
+	}
+}
+
+func TestWaitCancelsHttpRequests(t *testing.T) {
+	client := startTestWebServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done() // never responds
+	}))
+
+	challenge := &Challenge{
+		ID:      "1",
+		Domain:  "website.com",
+		Methods: &config.Methods{TextFile: &config.TextFileGenerator{FileName: "myapp.txt", Content: "1234"}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := Wait(ctx, challenge, &WaitOptions{HttpClient: client}); err != context.DeadlineExceeded {
+		t.Errorf("expected: %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the request to be canceled with the context, Wait returned after %v", elapsed)
+	}
+}
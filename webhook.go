@@ -0,0 +1,341 @@
+package domainverifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/segmentio/ksuid"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader is the header holding the signature of a webhook, sha256=<hex HMAC-SHA256>.
+	WebhookSignatureHeader = "X-Domainverifier-Signature"
+	// WebhookTimestampHeader is the header holding the Unix time a webhook was signed at.
+	WebhookTimestampHeader = "X-Domainverifier-Timestamp"
+
+	// DefaultWebhookRetries is the number of retries of a failed delivery, when none is configured.
+	DefaultWebhookRetries = 3
+	// DefaultWebhookBackoff is the delay before the first retry of a failed delivery, when none is configured.
+	DefaultWebhookBackoff = time.Second
+	// DefaultWebhookTimeout is the timeout of each delivery attempt, when none is configured.
+	DefaultWebhookTimeout = 10 * time.Second
+	// DefaultNotificationTimeout is the timeout of the delivery of an event by a NotificationHandler,
+	// retries included, when none is configured.
+	DefaultNotificationTimeout = time.Minute
+
+	webhookSignaturePrefix = "sha256="
+)
+
+var (
+	// WebhookDeliveryError indicates that the receiver of a webhook did not answer with a 2xx status.
+	WebhookDeliveryError = errors.New("the webhook was not accepted by the receiver")
+
+	// InvalidWebhookSignatureError indicates that the signature of a received webhook is invalid or too old.
+	InvalidWebhookSignatureError = errors.New("invalid webhook signature")
+
+	// NotificationHandlerClosedError indicates that an event was received after the NotificationHandler was closed.
+	NotificationHandlerClosedError = errors.New("the notification handler is closed")
+)
+
+// DefaultNotifiedStatuses lists the statuses notified by a NotificationHandler, when none is configured:
+// the outcomes of the verification, and its loss on re-check.
+var DefaultNotifiedStatuses = []ChallengeStatus{ChallengeVerified, ChallengeFailed, ChallengeExpired, ChallengeLost}
+
+// Notifier delivers the transitions of the challenges, e.g. to a webhook or a message queue.
+type Notifier interface {
+	Notify(ctx context.Context, event ChallengeEvent) error
+}
+
+// WebhookPayload is the JSON body of a webhook.
+type WebhookPayload struct {
+	ID         string     `json:"id"`   // unique ID of the delivery, the same for its retries
+	Type       string     `json:"type"` // challenge.<status>, e.g. challenge.verified
+	CreatedAt  time.Time  `json:"created_at"`
+	Challenge  *Challenge `json:"challenge"`
+	Transition Transition `json:"transition"`
+}
+
+// WebhookNotifier is a Notifier posting a signed WebhookPayload to a URL.
+// The body is signed with HMAC-SHA256 over "<timestamp>.<body>", the timestamp being sent in
+// WebhookTimestampHeader and the signature in WebhookSignatureHeader, see VerifyWebhookSignature.
+// A failed delivery (network error, 429 or 5xx status) is retried with an exponential backoff.
+type WebhookNotifier struct {
+	URL     string
+	Secret  string
+	Client  *http.Client  // http.DefaultClient when nil
+	Retries int           // retries of a failed delivery, DefaultWebhookRetries when 0, none when negative
+	Backoff time.Duration // delay before the first retry, doubled after each retry, DefaultWebhookBackoff when 0
+	Timeout time.Duration // timeout of each attempt, DefaultWebhookTimeout when 0
+}
+
+// Notify posts the event to the URL of the notifier, retrying until it is accepted,
+// the retries are exhausted or the context is done.
+func (n *WebhookNotifier) Notify(ctx context.Context, event ChallengeEvent) error {
+	if strings.TrimSpace(n.URL) == "" {
+		return errors.New("webhook URL cannot be empty")
+	}
+
+	body, err := json.Marshal(WebhookPayload{
+		ID:         ksuid.New().String(),
+		Type:       "challenge." + string(event.Transition.To),
+		CreatedAt:  time.Now().UTC(),
+		Challenge:  event.Challenge,
+		Transition: event.Transition,
+	})
+	if err != nil {
+		return err
+	}
+
+	retries := n.Retries
+	if retries == 0 {
+		retries = DefaultWebhookRetries
+	}
+	backoff := n.Backoff
+	if backoff <= 0 {
+		backoff = DefaultWebhookBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := n.deliver(ctx, body)
+		if err == nil || !retry || attempt >= retries {
+			return err
+		}
+
+		timer := time.NewTimer(backoff << attempt)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// deliver posts the body once and reports whether a failed delivery can be retried.
+func (n *WebhookNotifier) deliver(ctx context.Context, body []byte) (bool, error) {
+	timeout := n.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(attemptCtx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	// the timestamp is signed at each attempt, so that the retries are not rejected as too old
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(n.Secret, timestamp, body))
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("%w: %s", WebhookDeliveryError, resp.Status)
+}
+
+// SignWebhook returns the signature of a webhook body sent at the Unix timestamp, as sent in WebhookSignatureHeader.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature verifies the signature of a received webhook, from its WebhookTimestampHeader
+// and WebhookSignatureHeader headers. Webhooks signed more than tolerance ago are rejected to prevent replays,
+// unless tolerance is 0.
+//
+// Example:
+//
+//	body, _ := io.ReadAll(r.Body)
+//	err := domainverifier.VerifyWebhookSignature(secret, r.Header.Get(domainverifier.WebhookTimestampHeader),
+//		r.Header.Get(domainverifier.WebhookSignatureHeader), body, 5*time.Minute)
+func VerifyWebhookSignature(secret, timestamp, signature string, body []byte, tolerance time.Duration) error {
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return InvalidWebhookSignatureError
+	}
+	if tolerance > 0 {
+		age := time.Since(time.Unix(signedAt, 0))
+		if age > tolerance || age < -tolerance {
+			return InvalidWebhookSignatureError
+		}
+	}
+	if !hmac.Equal([]byte(signature), []byte(SignWebhook(secret, timestamp, body))) {
+		return InvalidWebhookSignatureError
+	}
+	return nil
+}
+
+// NotificationOptions configures a NotificationHandler.
+type NotificationOptions struct {
+	Statuses []ChallengeStatus // statuses whose transitions are notified, DefaultNotifiedStatuses when empty
+	Timeout  time.Duration     // timeout of the delivery of an event, retries included, DefaultNotificationTimeout when 0
+	// OnError, when not nil, is called with the events that could not be delivered.
+	OnError func(event ChallengeEvent, err error)
+}
+
+// NotificationHandler delivers the transitions of the challenges with a Notifier, in the background,
+// so that the retries do not block the transitions. The events of a challenge are delivered one at a time,
+// in the order of the transitions. Close stops the handler once the pending events are delivered.
+type NotificationHandler struct {
+	notifier Notifier
+	options  NotificationOptions
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex
+	queues map[string][]ChallengeEvent // events waiting for delivery, by challenge ID
+	closed bool
+}
+
+// NewNotificationHandler returns a handler delivering the events with the notifier.
+// Register its Handle method with LifecycleManager.OnTransition.
+//
+// Example:
+//
+//	notifier := &domainverifier.WebhookNotifier{URL: "https://myapp.com/hooks/domains", Secret: secret}
+//	handler := domainverifier.NewNotificationHandler(notifier, &domainverifier.NotificationOptions{
+//		OnError: func(event domainverifier.ChallengeEvent, err error) {
+//			log.Printf("cannot notify %s: %v", event.Challenge.ID, err)
+//		},
+//	})
+//	manager.OnTransition(handler.Handle)
+//	defer handler.Close(context.Background())
+func NewNotificationHandler(notifier Notifier, options *NotificationOptions) *NotificationHandler {
+	h := &NotificationHandler{notifier: notifier, queues: map[string][]ChallengeEvent{}}
+	if options != nil {
+		h.options = *options
+	}
+	if len(h.options.Statuses) == 0 {
+		h.options.Statuses = DefaultNotifiedStatuses
+	}
+	if h.options.Timeout <= 0 {
+		h.options.Timeout = DefaultNotificationTimeout
+	}
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	return h
+}
+
+// Handle queues the event for delivery when its status is notified.
+// The events received after Close are reported to OnError with NotificationHandlerClosedError.
+func (h *NotificationHandler) Handle(event ChallengeEvent) {
+	if !h.notified(event.Transition.To) {
+		return
+	}
+
+	id := ""
+	if event.Challenge != nil {
+		id = event.Challenge.ID
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		h.reportError(event, NotificationHandlerClosedError)
+		return
+	}
+	queue := h.queues[id]
+	h.queues[id] = append(queue, event)
+	if len(queue) == 0 {
+		// no delivery is running for the challenge
+		h.wg.Add(1)
+		go h.deliver(id)
+	}
+	h.mu.Unlock()
+}
+
+// Close stops accepting events and waits until the pending events are delivered or the context is done,
+// in which case the deliveries are canceled and the context error is returned.
+func (h *NotificationHandler) Close(ctx context.Context) error {
+	h.mu.Lock()
+	h.closed = true
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		h.cancel()
+		return nil
+	case <-ctx.Done():
+		h.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// deliver delivers the queued events of the challenge in order, until its queue is empty.
+func (h *NotificationHandler) deliver(id string) {
+	defer h.wg.Done()
+	for {
+		h.mu.Lock()
+		event := h.queues[id][0]
+		h.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(h.ctx, h.options.Timeout)
+		err := h.notifier.Notify(ctx, event)
+		cancel()
+		if err != nil {
+			h.reportError(event, err)
+		}
+
+		// the event is removed once delivered, so that Handle does not start another delivery meanwhile
+		h.mu.Lock()
+		queue := h.queues[id][1:]
+		if len(queue) == 0 {
+			delete(h.queues, id)
+			h.mu.Unlock()
+			return
+		}
+		h.queues[id] = queue
+		h.mu.Unlock()
+	}
+}
+
+func (h *NotificationHandler) notified(status ChallengeStatus) bool {
+	for _, notified := range h.options.Statuses {
+		if status == notified {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *NotificationHandler) reportError(event ChallengeEvent, err error) {
+	if h.options.OnError != nil {
+		h.options.OnError(event, err)
+	}
+}
@@ -0,0 +1,267 @@
+package domainverifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+func testChallengeEvent() ChallengeEvent {
+	challenge := newTestChallenge("website.com", "alice")
+	challenge.ID = "1"
+	challenge.Status = ChallengeVerified
+	return ChallengeEvent{
+		Challenge:  challenge,
+		Transition: Transition{From: ChallengePending, To: ChallengeVerified, At: time.Now().UTC()},
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var payload WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		err := VerifyWebhookSignature(testWebhookSecret, r.Header.Get(WebhookTimestampHeader),
+			r.Header.Get(WebhookSignatureHeader), body, time.Minute)
+		if err != nil || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.Unmarshal(body, &payload)
+	}))
+	defer server.Close()
+
+	notifier := &WebhookNotifier{URL: server.URL, Secret: testWebhookSecret}
+	if err := notifier.Notify(context.Background(), testChallengeEvent()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if payload.Type != "challenge.verified" || payload.ID == "" || payload.Challenge == nil ||
+		payload.Challenge.Domain != "website.com" || payload.Transition.From != ChallengePending {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+
+	notifier.Secret = "other-secret"
+	if err := notifier.Notify(context.Background(), testChallengeEvent()); !errors.Is(err, WebhookDeliveryError) {
+		t.Errorf("expected: %v, got: %v", WebhookDeliveryError, err)
+	}
+}
+
+func TestWebhookNotifierRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		retries   int
+		wantCalls int32
+		wantErr   bool
+	}{
+		{"accepted after retries", []int{503, 429, 200}, 0, 3, false},
+		{"client error is not retried", []int{400}, 0, 1, true},
+		{"retries exhausted", []int{500, 500, 500, 500}, 2, 3, true},
+		{"no retry", []int{500, 200}, -1, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call := atomic.AddInt32(&calls, 1)
+				w.WriteHeader(tt.statuses[call-1])
+			}))
+			defer server.Close()
+
+			notifier := &WebhookNotifier{URL: server.URL, Secret: testWebhookSecret, Retries: tt.retries, Backoff: time.Millisecond}
+			err := notifier.Notify(context.Background(), testChallengeEvent())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error: %v, got: %v", tt.wantErr, err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("expected: %v calls, got: %v", tt.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifierContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	notifier := &WebhookNotifier{URL: server.URL, Secret: testWebhookSecret, Retries: 10, Backoff: time.Second}
+	if err := notifier.Notify(ctx, testChallengeEvent()); err != context.DeadlineExceeded {
+		t.Errorf("expected: %v, got: %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestWebhookNotifierTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // a receiver that never answers
+	}))
+	defer server.Close()
+	defer close(release)
+
+	notifier := &WebhookNotifier{URL: server.URL, Secret: testWebhookSecret, Retries: -1, Timeout: 50 * time.Millisecond}
+	if err := notifier.Notify(context.Background(), testChallengeEvent()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected: %v, got: %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		body      []byte
+		tolerance time.Duration
+		wantErr   bool
+	}{
+		{"valid", now, SignWebhook(testWebhookSecret, now, body), body, time.Minute, false},
+		{"wrong secret", now, SignWebhook("other", now, body), body, time.Minute, true},
+		{"tampered body", now, SignWebhook(testWebhookSecret, now, body), []byte(`{"id":"2"}`), time.Minute, true},
+		{"replayed", old, SignWebhook(testWebhookSecret, old, body), body, time.Minute, true},
+		{"no tolerance", old, SignWebhook(testWebhookSecret, old, body), body, 0, false},
+		{"invalid timestamp", "now", SignWebhook(testWebhookSecret, "now", body), body, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(testWebhookSecret, tt.timestamp, tt.signature, tt.body, tt.tolerance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// notifierFunc is a Notifier calling a function.
+type notifierFunc func(ctx context.Context, event ChallengeEvent) error
+
+func (f notifierFunc) Notify(ctx context.Context, event ChallengeEvent) error {
+	return f(ctx, event)
+}
+
+func TestNotificationHandler(t *testing.T) {
+	received := make(chan WebhookPayload, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+	}))
+	defer server.Close()
+
+	manager, _, now := newTestLifecycle(t, &LifecycleOptions{TTL: time.Hour})
+	handler := NewNotificationHandler(&WebhookNotifier{URL: server.URL, Secret: testWebhookSecret}, nil)
+	manager.OnTransition(handler.Handle)
+	revoked := createTestChallenge(t, manager, "website.com", *now)
+	if _, err := manager.Revoke(revoked, "the domain changed hands"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expired := createTestChallenge(t, manager, "website.com", now.Add(-2*time.Hour))
+	if _, err := manager.ExpirePending(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	close(received)
+	var payloads []WebhookPayload
+	for payload := range received {
+		payloads = append(payloads, payload)
+	}
+	if len(payloads) != 1 || payloads[0].Type != "challenge.expired" || payloads[0].Challenge.ID != expired {
+		t.Errorf("expected only the expired challenge to be notified, got: %+v", payloads)
+	}
+
+	failed := make(chan error, 1)
+	handler = NewNotificationHandler(&WebhookNotifier{URL: "http://127.0.0.1:1", Retries: -1}, &NotificationOptions{
+		Statuses: []ChallengeStatus{ChallengeRevoked},
+		OnError: func(event ChallengeEvent, err error) {
+			failed <- err
+		},
+	})
+	event := testChallengeEvent()
+	event.Transition.To = ChallengeRevoked
+	handler.Handle(event)
+	if err := handler.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := <-failed; err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestNotificationHandlerOrder(t *testing.T) {
+	var mu sync.Mutex
+	delivered := map[string][]ChallengeStatus{}
+	handler := NewNotificationHandler(notifierFunc(func(ctx context.Context, event ChallengeEvent) error {
+		time.Sleep(time.Millisecond) // let the events of the other challenge interleave
+		mu.Lock()
+		defer mu.Unlock()
+		delivered[event.Challenge.ID] = append(delivered[event.Challenge.ID], event.Transition.To)
+		return nil
+	}), &NotificationOptions{Statuses: []ChallengeStatus{ChallengeVerified, ChallengeLost}})
+
+	want := []ChallengeStatus{ChallengeVerified, ChallengeLost, ChallengeVerified, ChallengeLost}
+	for _, status := range want {
+		for _, id := range []string{"1", "2"} {
+			event := testChallengeEvent()
+			event.Challenge.ID = id
+			event.Transition.To = status
+			handler.Handle(event)
+		}
+	}
+	if err := handler.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		if !reflect.DeepEqual(delivered[id], want) {
+			t.Errorf("challenge %s: expected: %v, got: %v", id, want, delivered[id])
+		}
+	}
+}
+
+func TestNotificationHandlerClose(t *testing.T) {
+	var errs []error
+	var mu sync.Mutex
+	handler := NewNotificationHandler(notifierFunc(func(ctx context.Context, event ChallengeEvent) error {
+		<-ctx.Done() // a receiver that never answers
+		return ctx.Err()
+	}), &NotificationOptions{
+		OnError: func(event ChallengeEvent, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		},
+	})
+	handler.Handle(testChallengeEvent())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := handler.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected: %v, got: %v", context.DeadlineExceeded, err)
+	}
+	handler.Handle(testChallengeEvent())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 2 || errs[0] != context.Canceled || errs[1] != NotificationHandlerClosedError {
+		t.Errorf("expected: %v, got: %v", []error{context.Canceled, NotificationHandlerClosedError}, errs)
+	}
+}